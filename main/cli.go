@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/api"
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+const cliUsage = `Usage: tasmota-alerter [command]
+
+Without command the alerter daemon is started. Commands talk to already running daemon (API_LISTEN):
+  alerts                          Show fired alerts.
+  ack <device> <rule-id> [user]   Acknowledge active alert. Reminders stop, alert stays active until resolved.
+  silence list                    Show active and pending silences.
+  silence add [options]           Mute notifications for device (or glob like plug-*) and/or rule ID. Options:
+      -device <glob> -rule <rule-id> -start <time> -end <time> -duration <2h> -comment <text>
+      Time is in format "2006-01-02 15:04" (local time) or RFC3339. Default start is now.
+  silence expire <silence-id>     Remove silence.
+  safety list                     Show state of safety shutoff rules.
+  safety reset <device> <rule-id> Reset lockout of safety shutoff rule. Device is not switched on automatically.`
+
+// Run CLI command against running daemon
+func runCommand(v *vars, args []string) error {
+	switch args[0] {
+	case "alerts":
+		return callApi("GET", v.apiListen, "/alerts", nil)
+	case "ack":
+		if len(args) < 3 {
+			return fmt.Errorf("device and rule id are required\n%v", cliUsage)
+		}
+		request := api.AckRequest{Device: args[1], Rule: args[2], User: orDefault(os.Getenv("USER"), "cli")}
+		if len(args) > 3 {
+			request.User = args[3]
+		}
+		return callApi("POST", v.apiListen, "/alerts/ack", request)
+	case "silence":
+		return silenceCommand(v, args[1:])
+	case "safety":
+		if len(args) > 1 && args[1] == "list" {
+			return callApi("GET", v.apiListen, "/safety", nil)
+		}
+		if len(args) > 3 && args[1] == "reset" {
+			request := api.SafetyResetRequest{Device: args[2], Rule: args[3], User: orDefault(os.Getenv("USER"), "cli")}
+			return callApi("POST", v.apiListen, "/safety/reset", request)
+		}
+		return fmt.Errorf("use safety list or safety reset <device> <rule-id>\n%v", cliUsage)
+	case "help", "-h", "--help":
+		fmt.Println(cliUsage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%v", args[0], cliUsage)
+	}
+}
+
+func silenceCommand(v *vars, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("silence subcommand is required\n%v", cliUsage)
+	}
+	switch args[0] {
+	case "list":
+		return callApi("GET", v.apiListen, "/silences", nil)
+	case "add":
+		flags := flag.NewFlagSet("silence add", flag.ContinueOnError)
+		device := flags.String("device", "", "device name or glob")
+		rule := flags.String("rule", "", "rule ID")
+		start := flags.String("start", "", "start time")
+		end := flags.String("end", "", "end time")
+		duration := flags.String("duration", "", "duration like 2h")
+		comment := flags.String("comment", "", "comment")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		request := api.SilenceRequest{Duration: *duration}
+		request.Device = *device
+		request.RuleID = *rule
+		request.Comment = *comment
+		request.CreatedBy = orDefault(os.Getenv("USER"), "cli")
+		var err error
+		if request.StartsAt, err = parseCliTime(*start); err != nil {
+			return err
+		}
+		if request.EndsAt, err = parseCliTime(*end); err != nil {
+			return err
+		}
+		return callApi("POST", v.apiListen, "/silences", request)
+	case "expire":
+		if len(args) < 2 {
+			return fmt.Errorf("silence id is required\n%v", cliUsage)
+		}
+		return callApi("DELETE", v.apiListen, "/silences?id="+url.QueryEscape(args[1]), nil)
+	default:
+		return fmt.Errorf("unknown silence subcommand %q\n%v", args[0], cliUsage)
+	}
+}
+
+func parseCliTime(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("can't parse time %q, use \"2006-01-02 15:04\" or RFC3339", value)
+	}
+	return t, nil
+}
+
+func callApi(method string, apiListen string, path string, request any) error {
+	var textData string
+	if request != nil {
+		jsonData, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		textData = string(jsonData)
+	}
+	statusCode, body := http.CallUrlWithHeaders(method, "http://"+apiListen+path, []string{"Content-Type: application/json"}, textData)
+	if statusCode == 0 {
+		return fmt.Errorf("can't reach running tasmota-alerter API on %v", apiListen)
+	}
+	fmt.Println(strings.TrimSpace(body.String()))
+	if statusCode >= 400 {
+		return fmt.Errorf("API returned status %v", statusCode)
+	}
+	return nil
+}
@@ -6,7 +6,9 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/jorycz/tasmota-alerter/pkg/api"
 	"github.com/jorycz/tasmota-alerter/pkg/mqttclient"
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
 	"github.com/jorycz/tasmota-alerter/pkg/processor"
 )
 
@@ -17,8 +19,20 @@ func main() {
 		abort("Error reading env variables, exiting ...", "error", err)
 	}
 
+	// Command for already running daemon
+	if len(os.Args) > 1 {
+		if err := runCommand(v, os.Args[1:]); err != nil {
+			abort("Command failed.", "error", err)
+		}
+		return
+	}
+
 	slog.Info("Connecting to MQTT", "server", v.mqttHost, "port", v.mqttPort, "username", v.mqttUsername)
 	mqttClient := mqttclient.NewMqttClient(v.mqttHost, v.mqttPort, v.mqttUsername, v.mqttPassword, v.mqttClientId)
+	if v.haDiscovery {
+		// Entities in Home Assistant are unavailable while alerter is not running
+		mqttClient.SetAvailabilityTopic(processor.AlerterAvailabilityTopic)
+	}
 	if err := mqttClient.Connect(); err != nil {
 		abort("Error connecting to MQTT broker, exiting ...", "error", err)
 	}
@@ -28,6 +42,14 @@ func main() {
 		abort("Error subscribing topics, exiting ...", "error", err)
 	}
 
+	if v.haDiscovery {
+		if err := processor.EnableHaDiscovery(v.haDiscoveryPrefix); err != nil {
+			abort("Error enabling Home Assistant discovery, exiting ...", "error", err)
+		}
+	}
+	api.Serve(v.apiListen)
+	notificationengine.StartTelegramAckPolling(v.telegramAckPollSeconds, processor.AcknowledgeAlert)
+
 	// Create a channel to receive signals - for graceful shutdown
 	signalCh := make(chan os.Signal, 1)
 	// Notify the channel for specific OS signals
@@ -49,6 +71,8 @@ func osSignalReceived(sig os.Signal) {
 	case os.Interrupt, syscall.SIGTERM:
 		// Store already fired alerts to prevent false alarms in case of reboot for example
 		processor.StoreFiredAlerts()
+		processor.StoreAlertHistory()
+		processor.StoreDeviceStats()
 		os.Exit(0)
 	case syscall.SIGHUP, syscall.SIGUSR1:
 		// Dump current alerts to log
@@ -9,9 +9,11 @@ import (
 )
 
 type vars struct {
-	mqttHost, mqttUsername, mqttPassword, mqttClientId, smtpServer string
-	mqttPort, statusUpdateSeconds                                  int
-	mqttTopics                                                     []string
+	mqttHost, mqttUsername, mqttPassword, mqttClientId, smtpServer, apiListen string
+	mqttPort, statusUpdateSeconds, telegramAckPollSeconds                     int
+	mqttTopics                                                                []string
+	haDiscovery                                                               bool
+	haDiscoveryPrefix                                                         string
 }
 
 func ReadEnv() (*vars, error) {
@@ -32,6 +34,19 @@ func ReadEnv() (*vars, error) {
 	}
 	v.statusUpdateSeconds = statusUpdateSeconds
 
+	v.apiListen = orDefault(os.Getenv("API_LISTEN"), "localhost:8089")
+	telegramAckPollSeconds, err := strconv.Atoi(orDefault(os.Getenv("TELEGRAM_ACK_POLL_SECONDS"), "0"))
+	if err != nil {
+		return nil, fmt.Errorf("can't parse provided telegram acknowledgement poll interval: %s", err)
+	}
+	v.telegramAckPollSeconds = telegramAckPollSeconds
+	haDiscovery, err := strconv.ParseBool(orDefault(os.Getenv("HA_DISCOVERY"), "false"))
+	if err != nil {
+		return nil, fmt.Errorf("can't parse provided home assistant discovery switch: %s", err)
+	}
+	v.haDiscovery = haDiscovery
+	v.haDiscoveryPrefix = orDefault(os.Getenv("HA_DISCOVERY_PREFIX"), "homeassistant")
+
 	smtpServerHost := orDefault(os.Getenv("SMTP_SERVER_HOST"), "localhost")
 	smtpServerPort := orDefault(os.Getenv("SMTP_SERVER_PORT"), "25")
 	v.smtpServer = strings.Join([]string{smtpServerHost, smtpServerPort}, ":")
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/processor"
+)
+
+type AckRequest struct {
+	Device string `json:"device"`
+	Rule   string `json:"rule"`
+	User   string `json:"user"`
+}
+
+type SafetyResetRequest struct {
+	Device string `json:"device"`
+	Rule   string `json:"rule"`
+	User   string `json:"user"`
+}
+
+// Silence ends at EndsAt or after Duration (like 2h30m) from its start
+type SilenceRequest struct {
+	processor.Silence
+	Duration string `json:"duration,omitempty"`
+}
+
+type response struct {
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Serve starts HTTP API of running daemon in background. Used by CLI commands as well.
+func Serve(listenAddress string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/alerts", alertsHandler)
+	mux.HandleFunc("/alerts/ack", ackHandler)
+	mux.HandleFunc("/silences", silencesHandler)
+	mux.HandleFunc("/safety", safetyHandler)
+	mux.HandleFunc("/safety/reset", safetyResetHandler)
+
+	go func() {
+		slog.Info("API listening.", "address", listenAddress)
+		if err := http.ListenAndServe(listenAddress, mux); err != nil {
+			slog.Error("API server stopped.", "error", err)
+		}
+	}()
+}
+
+func alertsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+		return
+	}
+	jsonData, err := processor.FiredAlertsAsJson()
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, response{Error: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
+
+func ackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+		return
+	}
+	var request AckRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJson(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+	if len(request.Device) == 0 || len(request.Rule) == 0 {
+		writeJson(w, http.StatusBadRequest, response{Error: "device and rule are required"})
+		return
+	}
+	if len(request.User) == 0 {
+		request.User = "api"
+	}
+	if err := processor.AcknowledgeAlert(request.Device, request.Rule, request.User); err != nil {
+		writeJson(w, http.StatusNotFound, response{Error: err.Error()})
+		return
+	}
+	writeJson(w, http.StatusOK, response{Status: "acknowledged"})
+}
+
+func silencesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, processor.ListSilences())
+	case http.MethodPost:
+		var request SilenceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJson(w, http.StatusBadRequest, response{Error: err.Error()})
+			return
+		}
+		silence := request.Silence
+		if silence.StartsAt.IsZero() {
+			silence.StartsAt = time.Now()
+		}
+		if silence.EndsAt.IsZero() && len(request.Duration) > 0 {
+			duration, err := time.ParseDuration(request.Duration)
+			if err != nil {
+				writeJson(w, http.StatusBadRequest, response{Error: err.Error()})
+				return
+			}
+			silence.EndsAt = silence.StartsAt.Add(duration)
+		}
+		silence, err := processor.AddSilence(silence)
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, response{Error: err.Error()})
+			return
+		}
+		writeJson(w, http.StatusOK, silence)
+	case http.MethodDelete:
+		if err := processor.ExpireSilence(r.URL.Query().Get("id")); err != nil {
+			writeJson(w, http.StatusNotFound, response{Error: err.Error()})
+			return
+		}
+		writeJson(w, http.StatusOK, response{Status: "expired"})
+	default:
+		writeJson(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+	}
+}
+
+func safetyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+		return
+	}
+	writeJson(w, http.StatusOK, processor.ListSafetyStates())
+}
+
+func safetyResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, response{Error: "method not allowed"})
+		return
+	}
+	var request SafetyResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJson(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+	if len(request.User) == 0 {
+		request.User = "api"
+	}
+	if err := processor.ResetSafetyLockout(request.Device, request.Rule, request.User); err != nil {
+		writeJson(w, http.StatusNotFound, response{Error: err.Error()})
+		return
+	}
+	writeJson(w, http.StatusOK, response{Status: "reset"})
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Error encoding API response.", "error", err)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAckHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "wrong method", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed, wantError: "method not allowed"},
+		{name: "invalid json", method: http.MethodPost, body: "{", wantStatus: http.StatusBadRequest},
+		{name: "missing rule", method: http.MethodPost, body: `{"device":"plug-freezer"}`, wantStatus: http.StatusBadRequest, wantError: "device and rule are required"},
+		{name: "no active alert", method: http.MethodPost, body: `{"device":"plug-freezer","rule":"freezer-stopped"}`, wantStatus: http.StatusNotFound, wantError: "no active alert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ackHandler(recorder, httptest.NewRequest(tt.method, "/alerts/ack", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", recorder.Code, tt.wantStatus)
+			}
+			var got response
+			if err := json.NewDecoder(recorder.Body).Decode(&got); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if len(got.Error) == 0 || !strings.Contains(got.Error, tt.wantError) {
+				t.Errorf("error = %q, want %q", got.Error, tt.wantError)
+			}
+		})
+	}
+}
@@ -27,31 +27,58 @@ func CallUrlWithOptionalTextData(url string, textData string) (statusCode int, b
 	}
 	response, err := sendRequest(method, url, payload)
 	if err != nil {
-		slog.Error("Error sending request: %v\n", err)
+		slog.Error("Error sending request.", "error", err)
 		return 0, nil
 	}
 	responseBody := new(bytes.Buffer)
 	_, err = responseBody.ReadFrom(response.Body)
 	if err != nil {
-		slog.Error("Error reading response: %v\n", err)
+		slog.Error("Error reading response.", "error", err)
 		return 0, nil
 	}
 	return response.StatusCode, responseBody
 }
 
+// Headers are passed with the request, so it is safe to call concurrently with SetHeader users
+func CallUrlWithHeaders(method, url string, requestHeaders []string, textData string) (statusCode int, body *bytes.Buffer) {
+	statusCode, body, _ = CallUrlWithResponseHeaders(method, url, requestHeaders, textData)
+	return statusCode, body
+}
+
+// Same as CallUrlWithHeaders, headers of response are returned too (like rate limit headers)
+func CallUrlWithResponseHeaders(method, url string, requestHeaders []string, textData string) (statusCode int, body *bytes.Buffer, responseHeaders http.Header) {
+	var payload []byte
+	if len(textData) > 0 {
+		payload = []byte(textData)
+	}
+	response, err := sendRequestWithHeaders(method, url, payload, requestHeaders)
+	if err != nil {
+		slog.Error("Error sending request.", "error", err)
+		return 0, nil, nil
+	}
+	defer response.Body.Close()
+	responseBody := new(bytes.Buffer)
+	_, err = responseBody.ReadFrom(response.Body)
+	if err != nil {
+		slog.Error("Error reading response.", "error", err)
+		return 0, nil, nil
+	}
+	return response.StatusCode, responseBody, response.Header
+}
+
 func CallUrlForDelete(url string) (statusCode int, body *bytes.Buffer) {
 	var payload []byte
 	var method = "DELETE"
 
 	response, err := sendRequest(method, url, payload)
 	if err != nil {
-		slog.Error("Error sending request: %v\n", err)
+		slog.Error("Error sending request.", "error", err)
 		return 0, nil
 	}
 	responseBody := new(bytes.Buffer)
 	_, err = responseBody.ReadFrom(response.Body)
 	if err != nil {
-		slog.Error("Error reading response: %v\n", err)
+		slog.Error("Error reading response.", "error", err)
 		return 0, nil
 	}
 	return response.StatusCode, responseBody
@@ -60,6 +87,17 @@ func CallUrlForDelete(url string) (statusCode int, body *bytes.Buffer) {
 // PRIVATE
 
 func sendRequest(method, urlStr string, payload []byte) (*http.Response, error) {
+	resp, err := sendRequestWithHeaders(method, urlStr, payload, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete all headers
+	headers = []string{}
+	return resp, nil
+}
+
+func sendRequestWithHeaders(method, urlStr string, payload []byte, requestHeaders []string) (*http.Response, error) {
 	var timeout = 5 * time.Second
 	transport := &http.Transport{
 		// Here goes proxy setup if any ...
@@ -86,18 +124,14 @@ func sendRequest(method, urlStr string, payload []byte) (*http.Response, error)
 		}
 	}
 
-	for _, header := range headers {
+	for _, header := range requestHeaders {
 		slog.Debug("HTTP", "Header", header)
-		headerParts := strings.Split(header, ":")
-		req.Header.Set(headerParts[0], headerParts[1])
+		headerParts := strings.SplitN(header, ":", 2)
+		if len(headerParts) < 2 {
+			continue
+		}
+		req.Header.Set(strings.TrimSpace(headerParts[0]), strings.TrimSpace(headerParts[1]))
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Delete all headers
-	headers = []string{}
-	return resp, nil
+	return client.Do(req)
 }
@@ -8,7 +8,10 @@ import (
 )
 
 type MqttClient struct {
-	c mqtt.Client
+	c       mqtt.Client
+	options *mqtt.ClientOptions
+	// Broker publishes "offline" to this topic when connection is lost, "online" is published after connect
+	availabilityTopic string
 }
 
 func NewMqttClient(host string, port int, user, password string, client_id string) *MqttClient {
@@ -23,11 +26,19 @@ func NewMqttClient(host string, port int, user, password string, client_id strin
 	options.OnConnect = mqttClient.connectionHandler
 	options.OnConnectionLost = mqttClient.connectionLostHandler
 
+	mqttClient.options = options
 	mqttClient.c = mqtt.NewClient(options)
 
 	return mqttClient
 }
 
+// Must be called before Connect
+func (mc *MqttClient) SetAvailabilityTopic(topic string) {
+	mc.availabilityTopic = topic
+	mc.options.SetWill(topic, "offline", 1, true)
+	mc.c = mqtt.NewClient(mc.options)
+}
+
 func (mc *MqttClient) Connect() error {
 	token := mc.c.Connect()
 	if token.Wait() && token.Error() != nil {
@@ -52,8 +63,21 @@ func (mc *MqttClient) SendCommand(topic, payload string) error {
 	return nil
 }
 
+// Does not wait for delivery, so it can be called from message handler. Messages are sent in order of calls.
+func (mc *MqttClient) PublishAsync(topic string, qos byte, retain bool, payload []byte) {
+	token := mc.c.Publish(topic, qos, retain, payload)
+	go func() {
+		if token.Wait() && token.Error() != nil {
+			slog.Error("MQTT publish failed.", "topic", topic, "error", token.Error())
+		}
+	}()
+}
+
 func (mc *MqttClient) connectionHandler(c mqtt.Client) {
 	slog.Info("MQTT connected")
+	if len(mc.availabilityTopic) > 0 {
+		c.Publish(mc.availabilityTopic, 1, true, "online")
+	}
 }
 
 func (mc *MqttClient) connectionLostHandler(_ mqtt.Client, err error) {
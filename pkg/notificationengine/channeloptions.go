@@ -0,0 +1,104 @@
+package notificationengine
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const channelOptionsTag = "__OPTIONS__"
+
+// Delivery options of notification channel, set by __OPTIONS__:::CHANNEL_ID:::key=value:::key=value
+type channelOptions struct {
+	// Notifications are buffered and sent as one digest message after this window
+	Digest time.Duration
+	// Rate notifications per RateInterval, Burst notifications can be sent at once (default is Rate)
+	Rate         int
+	RateInterval time.Duration
+	Burst        int
+	// Count of notifications waiting for rate limit, others are collapsed into summary (default is 10)
+	Queue int
+}
+
+func (options channelOptions) burst() int {
+	if options.Burst > 0 {
+		return options.Burst
+	}
+	return options.Rate
+}
+
+var notificationChannelOptions map[string]channelOptions
+
+func optionsForChannel(channel string) channelOptions {
+	lock.Lock()
+	defer lock.Unlock()
+	return notificationChannelOptions[channel]
+}
+
+func parseChannelOptions(line string, parsed []string) {
+	if len(parsed) < 3 {
+		slog.Error("Can not parse channel options! Expected channel ID and at least one option after "+channelOptionsTag, "notification_line", line)
+		return
+	}
+	channel := strings.TrimSpace(parsed[1])
+	options := optionsForChannel(channel)
+	for _, option := range parsed[2:] {
+		if err := parseChannelOption(&options, option); err != nil {
+			slog.Error("Can not parse channel option!", "notification_line", line, "error", err)
+		}
+	}
+	lock.Lock()
+	notificationChannelOptions[channel] = options
+	lock.Unlock()
+}
+
+func parseChannelOption(options *channelOptions, option string) error {
+	key, value, found := strings.Cut(option, "=")
+	if !found {
+		return fmt.Errorf("option %q is not in key=value format", option)
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "digest":
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("can't parse digest window %q: %w", value, err)
+		}
+		options.Digest = window
+	case "rate":
+		// Count of notifications per interval, like 20/1m
+		rateStr, intervalStr, found := strings.Cut(value, "/")
+		if !found {
+			return fmt.Errorf("rate limit %q must be in format count/interval, like 20/1m", value)
+		}
+		rate, err := strconv.Atoi(rateStr)
+		if err != nil || rate < 1 {
+			return fmt.Errorf("rate limit count %q must be a positive number", rateStr)
+		}
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("can't parse rate limit interval %q", intervalStr)
+		}
+		options.Rate = rate
+		options.RateInterval = interval
+	case "burst":
+		burst, err := strconv.Atoi(value)
+		if err != nil || burst < 1 {
+			return fmt.Errorf("burst %q must be a positive number", value)
+		}
+		options.Burst = burst
+	case "queue":
+		queue, err := strconv.Atoi(value)
+		if err != nil || queue < 1 {
+			return fmt.Errorf("queue size %q must be a positive number", value)
+		}
+		options.Queue = queue
+	default:
+		return fmt.Errorf("unknown channel option %q", key)
+	}
+	return nil
+}
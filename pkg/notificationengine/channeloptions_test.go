@@ -0,0 +1,39 @@
+package notificationengine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseChannelOption(t *testing.T) {
+	tests := []struct {
+		option  string
+		want    channelOptions
+		wantErr bool
+	}{
+		{option: "digest=5m", want: channelOptions{Digest: 5 * time.Minute}},
+		{option: " digest = 30s ", want: channelOptions{Digest: 30 * time.Second}},
+		{option: "digest=soon", wantErr: true},
+		{option: "rate=20/1m", want: channelOptions{Rate: 20, RateInterval: time.Minute}},
+		{option: "rate=20", wantErr: true},
+		{option: "rate=0/1m", wantErr: true},
+		{option: "rate=20/0s", wantErr: true},
+		{option: "burst=5", want: channelOptions{Burst: 5}},
+		{option: "burst=0", wantErr: true},
+		{option: "queue=50", want: channelOptions{Queue: 50}},
+		{option: "queue=-1", wantErr: true},
+		{option: "digest", wantErr: true},
+		{option: "color=red", wantErr: true},
+	}
+	for _, tt := range tests {
+		var options channelOptions
+		err := parseChannelOption(&options, tt.option)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseChannelOption(%q) error = %v, wantErr %v", tt.option, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && options != tt.want {
+			t.Errorf("parseChannelOption(%q) = %+v, want %+v", tt.option, options, tt.want)
+		}
+	}
+}
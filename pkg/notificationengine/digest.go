@@ -0,0 +1,69 @@
+package notificationengine
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Buffered notifications per channel waiting for digest window to pass
+	digests     = map[string][]Notification{}
+	digestsLock sync.Mutex
+)
+
+// First notification starts digest window of channel, all notifications within the window are sent together
+func addToDigest(channel string, window time.Duration, notification Notification) {
+	digestsLock.Lock()
+	defer digestsLock.Unlock()
+
+	if len(digests[channel]) == 0 {
+		slog.Debug("DIGEST - Window started.", "channel", channel, "window", window)
+		time.AfterFunc(window, func() { flushDigest(channel) })
+	}
+	digests[channel] = append(digests[channel], notification)
+}
+
+func flushDigest(channel string) {
+	digestsLock.Lock()
+	notifications := digests[channel]
+	delete(digests, channel)
+	digestsLock.Unlock()
+
+	if len(notifications) == 0 {
+		return
+	}
+	slog.Debug("DIGEST - Sending.", "channel", channel, "count", len(notifications))
+	submitToChannel(channel, digestNotification(notifications))
+}
+
+// Single notification is sent as is, more notifications are grouped by device with count
+func digestNotification(notifications []Notification) Notification {
+	if len(notifications) == 1 {
+		return notifications[0]
+	}
+
+	byDevice := map[string][]Notification{}
+	for _, notification := range notifications {
+		byDevice[notification.Device] = append(byDevice[notification.Device], notification)
+	}
+	devices := make([]string, 0, len(byDevice))
+	for device := range byDevice {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "%v notifications from %v devices:\n", len(notifications), len(devices))
+	for _, device := range devices {
+		fmt.Fprintf(&message, "\n[ %v ] (%v)\n", device, len(byDevice[device]))
+		for _, notification := range byDevice[device] {
+			fmt.Fprintf(&message, "- %v %v\n", notification.Time.Format(time.TimeOnly), notification.Message)
+		}
+	}
+
+	return Notification{Message: strings.TrimSpace(message.String()), Time: time.Now()}
+}
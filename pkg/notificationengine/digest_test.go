@@ -0,0 +1,77 @@
+package notificationengine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestNotification(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 15, 0, time.Local)
+	tests := []struct {
+		name          string
+		notifications []Notification
+		want          string
+	}{
+		{
+			name:          "single notification is sent as is",
+			notifications: []Notification{{Device: "plug-fridge", Message: "Fridge power is high.", Time: at}},
+			want:          "Fridge power is high.",
+		},
+		{
+			name: "grouped by device",
+			notifications: []Notification{
+				{Device: "plug-freezer", Message: "Freezer is not running!", Time: at},
+				{Device: "plug-fridge", Message: "Fridge power is high.", Time: at.Add(time.Minute)},
+				{Device: "plug-freezer", Message: "Freezer is running again.", Time: at.Add(2 * time.Minute)},
+			},
+			want: "3 notifications from 2 devices:\n\n" +
+				"[ plug-freezer ] (2)\n- 12:30:15 Freezer is not running!\n- 12:32:15 Freezer is running again.\n\n" +
+				"[ plug-fridge ] (1)\n- 12:31:15 Fridge power is high.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := digestNotification(tt.notifications); got.Message != tt.want {
+				t.Errorf("digestNotification() =\n%v\nwant\n%v", got.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlushDigest(t *testing.T) {
+	channel := "TEST_DIGEST"
+	addToDigest(channel, time.Hour, Notification{Device: "plug-fridge", Message: "first"})
+	addToDigest(channel, time.Hour, Notification{Device: "plug-fridge", Message: "second"})
+	digestsLock.Lock()
+	buffered := len(digests[channel])
+	digestsLock.Unlock()
+	if buffered != 2 {
+		t.Fatalf("buffered %v notifications, want 2", buffered)
+	}
+
+	flushDigest(channel)
+	digestsLock.Lock()
+	_, found := digests[channel]
+	digestsLock.Unlock()
+	if found {
+		t.Error("digest is still buffered after flush")
+	}
+	// Flush of empty digest (like when window timer fires after manual flush) does nothing
+	flushDigest(channel)
+}
+
+func TestDigestWindowFlushes(t *testing.T) {
+	channel := "TEST_DIGEST_WINDOW"
+	addToDigest(channel, 10*time.Millisecond, Notification{Message: "first"})
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		digestsLock.Lock()
+		_, found := digests[channel]
+		digestsLock.Unlock()
+		if !found {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("digest was not flushed after window")
+}
@@ -0,0 +1,120 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+// Limits of embed, https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldNameLimit   = 256
+	discordFieldValueLimit  = 1024
+	discordEmbedTotalLimit  = 6000
+)
+
+type discordMessage struct {
+	Username  string         `json:"username,omitempty"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+	Embeds    []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+	Timestamp   string         `json:"timestamp"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+type discordRateLimitResponse struct {
+	RetryAfter float64 `json:"retry_after"`
+}
+
+var discordSeverityColors = map[string]int{"info": 0x439fe0, "warning": 0xdaa038, "critical": 0xd00000}
+
+// Channel is configured like DISCORD_HOME:::url=https://discord.com/api/webhooks/...:::username=tasmota-alerter
+func sendDiscordWithNotification(channel string, fields []string, notification Notification) time.Duration {
+	settings, _ := channelConfig(fields)
+	if len(settings["url"]) == 0 {
+		slog.Error("DISCORD - Channel must have url configured.", "channel", channel)
+		return 0
+	}
+
+	message := discordMessage{
+		Username:  settings["username"],
+		AvatarURL: settings["avatar_url"],
+		Embeds:    []discordEmbed{discordEmbedOfNotification(notification)},
+	}
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("Error encoding discord message.", "error", err)
+		return 0
+	}
+
+	// Wait for the message, so errors are reported in response
+	httpStatusCode, responseBody, responseHeaders := http.CallUrlWithResponseHeaders("POST", settings["url"]+"?wait=true", []string{"Content-Type: application/json"}, string(jsonData))
+	// Bucket of webhook is empty, following messages must wait until it is reset
+	resetAfter := time.Duration(0)
+	if seconds, err := strconv.ParseFloat(responseHeaders.Get("X-RateLimit-Reset-After"), 64); err == nil {
+		resetAfter = time.Duration(seconds * float64(time.Second))
+	}
+
+	if httpStatusCode > 0 && httpStatusCode < 400 {
+		slog.Debug("DISCORD", "channel", channel, "status", httpStatusCode)
+		if responseHeaders.Get("X-RateLimit-Remaining") == "0" && resetAfter > 0 {
+			pauseChannel(channel, resetAfter)
+		}
+		return 0
+	}
+
+	retryAfter := time.Duration(0)
+	if httpStatusCode == 429 {
+		var rateLimit discordRateLimitResponse
+		if err := json.Unmarshal(responseBody.Bytes(), &rateLimit); err == nil && rateLimit.RetryAfter > 0 {
+			retryAfter = time.Duration(rateLimit.RetryAfter * float64(time.Second))
+		} else {
+			retryAfter = max(resetAfter, time.Second)
+		}
+	}
+	slog.Error("DISCORD", "channel", channel, "status", httpStatusCode, "response", responseBody.String(), "retry_after", retryAfter)
+	return retryAfter
+}
+
+func discordEmbedOfNotification(notification Notification) discordEmbed {
+	embed := discordEmbed{
+		Title:     truncate(notification.title(), discordTitleLimit),
+		Color:     discordSeverityColors[notification.effectiveSeverity()],
+		Footer:    &discordFooter{Text: "tasmota-alerter"},
+		Timestamp: notification.Time.Format(time.RFC3339),
+	}
+	if notification.State == StateResolved || notification.State == StateStable {
+		embed.Color = 0x2eb886
+	}
+
+	used := len([]rune(embed.Title)) + len([]rune(embed.Footer.Text))
+	for _, field := range notificationFields(notification) {
+		discordField := discordField{Name: truncate(field[0], discordFieldNameLimit), Value: truncate(field[1], discordFieldValueLimit), Inline: true}
+		used += len([]rune(discordField.Name)) + len([]rune(discordField.Value))
+		embed.Fields = append(embed.Fields, discordField)
+	}
+	// Message gets what is left from total size of embed
+	embed.Description = truncate(notification.Message, min(discordDescriptionLimit, discordEmbedTotalLimit-used))
+	return embed
+}
@@ -0,0 +1,116 @@
+package notificationengine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestDiscordEmbedOfNotification(t *testing.T) {
+	tests := []struct {
+		name            string
+		notification    Notification
+		wantColor       int
+		wantTitle       int
+		wantDescription int
+	}{
+		{
+			name:            "short message",
+			notification:    Notification{Device: "plug", RuleID: "power", State: StateFiring, Severity: "critical", Message: "Power is high."},
+			wantColor:       0xd00000,
+			wantTitle:       len("[ plug ] power firing"),
+			wantDescription: len("Power is high."),
+		},
+		{
+			name:            "resolved is green",
+			notification:    Notification{Device: "plug", RuleID: "power", State: StateResolved, Severity: "critical", Message: "Power is normal."},
+			wantColor:       0x2eb886,
+			wantTitle:       len("[ plug ] power resolved"),
+			wantDescription: len("Power is normal."),
+		},
+		{
+			name:            "long title and description",
+			notification:    Notification{Device: strings.Repeat("d", 300), State: StateReport, Message: strings.Repeat("m", 5000)},
+			wantColor:       0x439fe0,
+			wantTitle:       discordTitleLimit,
+			wantDescription: discordDescriptionLimit,
+		},
+		{
+			name:         "total size",
+			notification: Notification{Device: "plug", RuleID: "power", State: StateFiring, Severity: "warning", Value: strings.Repeat("v", 2000), Condition: strings.Repeat("c", 2000), Message: strings.Repeat("m", 5000)},
+			wantColor:    0xdaa038,
+			wantTitle:    len("[ plug ] power firing"),
+			// Total limit minus title, footer, field names and truncated field values
+			wantDescription: discordEmbedTotalLimit - len("[ plug ] power firing") - len("tasmota-alerter") - len("Device") - len("plug") - len("Value") - discordFieldValueLimit - len("Condition") - discordFieldValueLimit - len("Severity") - len("warning"),
+		},
+	}
+	for _, tt := range tests {
+		embed := discordEmbedOfNotification(tt.notification)
+		if embed.Color != tt.wantColor {
+			t.Errorf("%v: color = %x, want %x", tt.name, embed.Color, tt.wantColor)
+		}
+		if got := utf8.RuneCountInString(embed.Title); got != tt.wantTitle {
+			t.Errorf("%v: title length = %v, want %v", tt.name, got, tt.wantTitle)
+		}
+		if got := utf8.RuneCountInString(embed.Description); got != tt.wantDescription {
+			t.Errorf("%v: description length = %v, want %v", tt.name, got, tt.wantDescription)
+		}
+		for _, field := range embed.Fields {
+			if utf8.RuneCountInString(field.Value) > discordFieldValueLimit {
+				t.Errorf("%v: field %v is longer than limit", tt.name, field.Name)
+			}
+		}
+	}
+}
+
+func TestSendDiscord(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		headers        map[string]string
+		body           string
+		wantRetryAfter time.Duration
+		wantPaused     bool
+	}{
+		{name: "sent", status: http.StatusOK, headers: map[string]string{"X-RateLimit-Remaining": "3", "X-RateLimit-Reset-After": "2"}},
+		{name: "bucket empty", status: http.StatusOK, headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset-After": "2.5"}, wantPaused: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{"retry_after":1.5}`, wantRetryAfter: 1500 * time.Millisecond},
+		{name: "rate limited without body", status: http.StatusTooManyRequests, headers: map[string]string{"X-RateLimit-Reset-After": "3"}, wantRetryAfter: 3 * time.Second},
+		{name: "bad request", status: http.StatusBadRequest, body: `{"message":"Invalid Form Body"}`},
+	}
+	for _, tt := range tests {
+		limitersLock.Lock()
+		delete(limiters, "DISCORD_TEST")
+		limitersLock.Unlock()
+		var gotQuery string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotQuery = r.URL.RawQuery
+			for key, value := range tt.headers {
+				w.Header().Set(key, value)
+			}
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		retryAfter := sendDiscordWithNotification("DISCORD_TEST", []string{"url=" + server.URL}, Notification{Device: "plug", Message: "Power is high."})
+		server.Close()
+
+		if gotQuery != "wait=true" {
+			t.Errorf("%v: query = %q, want wait=true", tt.name, gotQuery)
+		}
+		if retryAfter != tt.wantRetryAfter {
+			t.Errorf("%v: retry after = %v, want %v", tt.name, retryAfter, tt.wantRetryAfter)
+		}
+		limitersLock.Lock()
+		paused := limiters["DISCORD_TEST"] != nil && limiters["DISCORD_TEST"].blockedUntil.After(time.Now())
+		limitersLock.Unlock()
+		if paused != tt.wantPaused {
+			t.Errorf("%v: channel paused = %v, want %v", tt.name, paused, tt.wantPaused)
+		}
+	}
+	limitersLock.Lock()
+	delete(limiters, "DISCORD_TEST")
+	limitersLock.Unlock()
+}
@@ -26,7 +26,7 @@ func sendEmailMessage(smtpDestination string, recipients []string, body string)
 
 			err := smtp.SendMail(smtpDestination, nil, from, []string{to}, message)
 			if err != nil {
-				slog.Error("Error sending e-mail.", err)
+				slog.Error("Error sending e-mail.", "error", err)
 			}
 		}
 	} else {
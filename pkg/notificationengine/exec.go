@@ -0,0 +1,144 @@
+package notificationengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExecTimeout     = 30 * time.Second
+	defaultExecConcurrency = 1
+	defaultExecQueue       = 10
+)
+
+type execJob struct {
+	path         string
+	timeout      time.Duration
+	notification Notification
+}
+
+// Runs of channel executable. Running jobs are counted regardless of changes of concurrency.
+type execRunner struct {
+	running     int
+	concurrency int
+	queued      []execJob
+}
+
+var (
+	execRunners     = map[string]*execRunner{}
+	execRunnersLock sync.Mutex
+)
+
+// Channel is configured like EXEC_SIREN:::path=/usr/local/bin/siren.sh:::timeout=10s:::concurrency=1:::queue=10
+func runExecWithNotification(channel string, config []string, notification Notification) {
+	settings, _ := channelConfig(config)
+	path := settings["path"]
+	if len(path) == 0 {
+		slog.Error("EXEC - Channel has no path configured.", "channel", channel)
+		return
+	}
+	timeout := durationSetting(channel, settings, "timeout", defaultExecTimeout)
+	concurrency := positiveSetting(channel, settings, "concurrency", defaultExecConcurrency)
+	queueSize := positiveSetting(channel, settings, "queue", defaultExecQueue)
+
+	// Executable can run long, notification must not block processing of MQTT messages
+	submitExec(channel, concurrency, queueSize, execJob{path: path, timeout: timeout, notification: notification})
+}
+
+func positiveSetting(channel string, settings map[string]string, key string, defaultValue int) int {
+	value, found := settings[key]
+	if !found {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		slog.Error("EXEC - Invalid setting, using default.", "channel", channel, key, value, "default", defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// Job runs at once while less than concurrency jobs of the channel are running, otherwise it waits in the queue. Job is dropped when the queue is full.
+func submitExec(channel string, concurrency int, queueSize int, job execJob) {
+	execRunnersLock.Lock()
+	defer execRunnersLock.Unlock()
+	runner := execRunners[channel]
+	if runner == nil {
+		runner = &execRunner{}
+		execRunners[channel] = runner
+	}
+	runner.concurrency = concurrency
+	if runner.running < concurrency {
+		runner.running++
+		go runExecJobs(channel, job)
+		return
+	}
+	if len(runner.queued) >= queueSize {
+		slog.Error("EXEC - Queue is full, notification dropped.", "channel", channel, "queued", len(runner.queued), "device", job.notification.Device, "rule", job.notification.RuleID, "state", job.notification.State)
+		return
+	}
+	runner.queued = append(runner.queued, job)
+}
+
+// Run job and then queued jobs of the channel until the queue is empty
+func runExecJobs(channel string, job execJob) {
+	for {
+		runExec(channel, job.path, job.timeout, job.notification)
+
+		execRunnersLock.Lock()
+		runner := execRunners[channel]
+		// Other running jobs take care of the queue when concurrency was lowered
+		if len(runner.queued) == 0 || runner.running > runner.concurrency {
+			runner.running--
+			execRunnersLock.Unlock()
+			return
+		}
+		job = runner.queued[0]
+		runner.queued = runner.queued[1:]
+		execRunnersLock.Unlock()
+	}
+}
+
+func runExec(channel string, path string, timeout time.Duration, notification Notification) {
+	jsonData, err := json.Marshal(notification)
+	if err != nil {
+		slog.Error("EXEC - Error encoding notification.", "channel", channel, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(jsonData)
+	cmd.Env = append(os.Environ(),
+		"TASMOTA_ALERTER_CHANNEL="+channel,
+		"TASMOTA_ALERTER_DEVICE="+notification.Device,
+		"TASMOTA_ALERTER_RULE="+notification.RuleID,
+		"TASMOTA_ALERTER_STATE="+notification.State,
+		"TASMOTA_ALERTER_VALUE="+notification.Value,
+		"TASMOTA_ALERTER_CONDITION="+notification.Condition,
+		"TASMOTA_ALERTER_MESSAGE="+notification.Message,
+		"TASMOTA_ALERTER_TIME="+notification.Time.Format(time.RFC3339),
+	)
+
+	started := time.Now()
+	output, err := cmd.CombinedOutput()
+	duration := time.Since(started).Round(time.Millisecond)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("killed after timeout %v", timeout)
+	}
+	if err != nil {
+		slog.Error("EXEC - Command failed.", "channel", channel, "path", path, "duration", duration, "error", err, "output", strings.TrimSpace(string(output)))
+		return
+	}
+	slog.Info("EXEC - Command finished.", "channel", channel, "path", path, "duration", duration, "output", strings.TrimSpace(string(output)))
+}
@@ -0,0 +1,125 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunExec(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	script := filepath.Join(dir, "notify.sh")
+	err := os.WriteFile(script, []byte("#!/bin/sh\nenv | grep ^TASMOTA_ALERTER_ | sort > "+output+"\ncat >> "+output+"\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification := Notification{Device: "plug", RuleID: "power", State: StateFiring, Value: "2500", Condition: ">2000", Message: "Power is high.", Time: time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)}
+	runExec("EXEC_TEST", script, time.Minute, notification)
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("executable did not run: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for _, want := range []string{
+		"TASMOTA_ALERTER_CHANNEL=EXEC_TEST",
+		"TASMOTA_ALERTER_CONDITION=>2000",
+		"TASMOTA_ALERTER_DEVICE=plug",
+		"TASMOTA_ALERTER_MESSAGE=Power is high.",
+		"TASMOTA_ALERTER_RULE=power",
+		"TASMOTA_ALERTER_STATE=firing",
+		"TASMOTA_ALERTER_TIME=2024-05-06T08:00:00Z",
+		"TASMOTA_ALERTER_VALUE=2500",
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("environment does not contain %q: %q", want, lines)
+		}
+	}
+	var stdin Notification
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &stdin); err != nil {
+		t.Fatalf("stdin is not notification JSON: %v", err)
+	}
+	if !reflect.DeepEqual(stdin, notification) {
+		t.Errorf("stdin = %+v, want %+v", stdin, notification)
+	}
+}
+
+func TestRunExecTimeout(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "slow.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 10\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	runExec("EXEC_TEST", script, 100*time.Millisecond, Notification{})
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("executable was not killed after timeout, ran %v", elapsed)
+	}
+}
+
+func TestSubmitExec(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output")
+	release := filepath.Join(dir, "release")
+	script := filepath.Join(dir, "wait.sh")
+	// Executable waits until the test releases it
+	err := os.WriteFile(script, []byte("#!/bin/sh\nwhile [ ! -f "+release+" ]; do sleep 0.01; done\necho $TASMOTA_ALERTER_MESSAGE >> "+output+"\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { execRunners = map[string]*execRunner{} })
+	runnerState := func() (int, int) {
+		execRunnersLock.Lock()
+		defer execRunnersLock.Unlock()
+		return execRunners["EXEC_TEST"].running, len(execRunners["EXEC_TEST"].queued)
+	}
+
+	tests := []struct {
+		message     string
+		concurrency int
+		wantRunning int
+		wantQueued  int
+	}{
+		{message: "first", concurrency: 1, wantRunning: 1},
+		{message: "queued", concurrency: 1, wantRunning: 1, wantQueued: 1},
+		{message: "dropped", concurrency: 1, wantRunning: 1, wantQueued: 1},
+		// Raised concurrency counts job which is already running
+		{message: "second", concurrency: 2, wantRunning: 2, wantQueued: 1},
+		{message: "dropped too", concurrency: 2, wantRunning: 2, wantQueued: 1},
+	}
+	for _, tt := range tests {
+		submitExec("EXEC_TEST", tt.concurrency, 1, execJob{path: script, timeout: time.Minute, notification: Notification{Message: tt.message}})
+		if running, queued := runnerState(); running != tt.wantRunning || queued != tt.wantQueued {
+			t.Errorf("%v: running %v, queued %v, want running %v, queued %v", tt.message, running, queued, tt.wantRunning, tt.wantQueued)
+		}
+	}
+
+	if err := os.WriteFile(release, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for running, _ := runnerState(); running > 0; running, _ = runnerState() {
+		if time.Now().After(deadline) {
+			t.Fatalf("executables are still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(string(data))
+	sort.Strings(lines)
+	if want := []string{"first", "queued", "second"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("executables run for %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,180 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFileKeep = 7
+	// Suffixes of files rotated daily and by size
+	fileRotateDateFormat = "2006-01-02"
+	fileRotateTimeFormat = "2006-01-02T150405.000"
+)
+
+// One line of FILE_ channel
+type fileRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Channel   string    `json:"channel"`
+	Device    string    `json:"device,omitempty"`
+	RuleID    string    `json:"rule,omitempty"`
+	State     string    `json:"state,omitempty"`
+	Value     string    `json:"value,omitempty"`
+	Condition string    `json:"condition,omitempty"`
+	Severity  string    `json:"severity,omitempty"`
+	Message   string    `json:"message"`
+}
+
+// Writes and rotation of files are serialized
+var fileLock sync.Mutex
+
+// Channel is configured like FILE_AUDIT:::path=storage/notifications.jsonl:::rotate=10MB:::keep=7 (rotate=daily rotates every day)
+func writeFileWithNotification(channel string, fields []string, notification Notification) {
+	settings, _ := channelConfig(fields)
+	path := settings["path"]
+	if len(path) == 0 {
+		slog.Error("FILE - Channel must have path configured.", "channel", channel)
+		return
+	}
+	keep := defaultFileKeep
+	if value, found := settings["keep"]; found {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			slog.Error("FILE - Invalid count of kept files, using default.", "channel", channel, "keep", value, "default", defaultFileKeep)
+		} else {
+			keep = parsed
+		}
+	}
+
+	jsonData, err := json.Marshal(fileRecord{
+		Timestamp: notification.Time,
+		Channel:   channel,
+		Device:    notification.Device,
+		RuleID:    notification.RuleID,
+		State:     notification.State,
+		Value:     notification.Value,
+		Condition: notification.Condition,
+		Severity:  notification.Severity,
+		Message:   notification.Message,
+	})
+	if err != nil {
+		slog.Error("Error encoding notification for file.", "error", err)
+		return
+	}
+	line := append(jsonData, '\n')
+
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	if err := rotateFileIfNeeded(path, settings["rotate"], keep, len(line)); err != nil {
+		slog.Error("FILE - Rotation failed.", "channel", channel, "path", path, "error", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		slog.Error("FILE", "channel", channel, "path", path, "error", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(line); err != nil {
+		slog.Error("FILE", "channel", channel, "path", path, "error", err)
+	}
+}
+
+// Rotated file gets suffix with date (daily) or time (size), only newest keep files are kept
+func rotateFileIfNeeded(path string, rotate string, keep int, nextWrite int) error {
+	if len(rotate) == 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		// Nothing to rotate yet
+		return nil
+	}
+
+	var suffix string
+	if rotate == "daily" {
+		modified := info.ModTime()
+		if modified.Format(fileRotateDateFormat) == time.Now().Format(fileRotateDateFormat) {
+			return nil
+		}
+		suffix = modified.Format(fileRotateDateFormat)
+	} else {
+		maxSize, err := parseSize(rotate)
+		if err != nil {
+			return err
+		}
+		if info.Size()+int64(nextWrite) <= maxSize {
+			return nil
+		}
+		suffix = time.Now().Format(fileRotateTimeFormat)
+	}
+
+	if err := os.Rename(path, path+"."+suffix); err != nil {
+		return err
+	}
+	rotated, err := rotatedFiles(path)
+	if err != nil {
+		return err
+	}
+	// Suffixes are sorted by time
+	for len(rotated) > keep {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Files rotated from path, other files in the same folder are not touched
+func rotatedFiles(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	var rotated []string
+	for _, entry := range entries {
+		suffix, found := strings.CutPrefix(entry.Name(), filepath.Base(path)+".")
+		if !found || entry.IsDir() || !isFileRotateSuffix(suffix) {
+			continue
+		}
+		rotated = append(rotated, filepath.Join(filepath.Dir(path), entry.Name()))
+	}
+	sort.Strings(rotated)
+	return rotated, nil
+}
+
+func isFileRotateSuffix(suffix string) bool {
+	for _, format := range []string{fileRotateDateFormat, fileRotateTimeFormat} {
+		if _, err := time.Parse(format, suffix); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Size like 500KB, 10MB or 1GB
+func parseSize(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	for _, unit := range units {
+		if number, found := strings.CutSuffix(upper, unit.suffix); found {
+			size, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+			if err != nil || size <= 0 {
+				break
+			}
+			return size * unit.multiplier, nil
+		}
+	}
+	return 0, fmt.Errorf("rotate %q must be daily or size like 10MB", value)
+}
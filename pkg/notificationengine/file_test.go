@@ -0,0 +1,184 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "500B", want: 500},
+		{value: "500KB", want: 500 << 10},
+		{value: "10MB", want: 10 << 20},
+		{value: "1GB", want: 1 << 30},
+		{value: " 10 mb ", want: 10 << 20},
+		{value: "10", wantErr: true},
+		{value: "0MB", wantErr: true},
+		{value: "-1MB", wantErr: true},
+		{value: "MB", wantErr: true},
+		{value: "weekly", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRotateFileIfNeeded(t *testing.T) {
+	yesterday := time.Now().AddDate(0, 0, -1)
+	tests := []struct {
+		name        string
+		rotate      string
+		keep        int
+		size        int
+		modified    time.Time
+		wantRotated int
+	}{
+		{name: "no rotation", rotate: "", size: 100, modified: yesterday, wantRotated: 0},
+		{name: "size not reached", rotate: "1KB", keep: 2, size: 100, modified: time.Now(), wantRotated: 0},
+		{name: "size reached", rotate: "100B", keep: 2, size: 100, modified: time.Now(), wantRotated: 1},
+		{name: "daily same day", rotate: "daily", keep: 2, size: 100, modified: time.Now(), wantRotated: 0},
+		{name: "daily next day", rotate: "daily", keep: 2, size: 100, modified: yesterday, wantRotated: 1},
+		{name: "keep none", rotate: "100B", keep: 0, size: 100, modified: time.Now(), wantRotated: 0},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "notifications.jsonl")
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", tt.size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, tt.modified, tt.modified); err != nil {
+			t.Fatal(err)
+		}
+		if err := rotateFileIfNeeded(path, tt.rotate, tt.keep, 10); err != nil {
+			t.Fatalf("%v: rotateFileIfNeeded() error = %v", tt.name, err)
+		}
+		rotated, _ := filepath.Glob(path + ".*")
+		if len(rotated) != tt.wantRotated {
+			t.Errorf("%v: rotated files = %v, want %v", tt.name, rotated, tt.wantRotated)
+		}
+		if tt.rotate == "daily" && len(rotated) == 1 && !strings.HasSuffix(rotated[0], "."+yesterday.Format(fileRotateDateFormat)) {
+			t.Errorf("%v: rotated file %v has not date of last modification", tt.name, rotated[0])
+		}
+	}
+}
+
+func TestRotateFileKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notifications.jsonl")
+	for _, name := range []string{"notifications.jsonl", "notifications.jsonl.2024-05-01", "notifications.jsonl.2024-05-02", "notifications.jsonl.2024-05-03"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rotateFileIfNeeded(path, "2B", 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 2 || filepath.Base(rotated[0]) != "notifications.jsonl.2024-05-03" {
+		t.Errorf("rotated files = %v, want 2024-05-03 and the new one", rotated)
+	}
+}
+
+func TestWriteFileWithNotification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	notificationTime := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	writeFileWithNotification("FILE_TEST", []string{"path=" + path}, Notification{Device: "plug", RuleID: "power", State: StateFiring, Message: "Power is high.", Time: notificationTime})
+	writeFileWithNotification("FILE_TEST", []string{"path=" + path}, Notification{State: StateReport, Message: "Report", Time: notificationTime})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	want := []string{
+		`{"timestamp":"2024-05-01T12:30:00Z","channel":"FILE_TEST","device":"plug","rule":"power","state":"firing","message":"Power is high."}`,
+		`{"timestamp":"2024-05-01T12:30:00Z","channel":"FILE_TEST","state":"report","message":"Report"}`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("file has lines %q, want %q", lines, want)
+	}
+	for idx, line := range lines {
+		var record fileRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil || line != want[idx] {
+			t.Errorf("line %v = %v, want %v", idx, line, want[idx])
+		}
+	}
+}
+
+func TestIsFileRotateSuffix(t *testing.T) {
+	tests := []struct {
+		suffix string
+		want   bool
+	}{
+		{suffix: "2024-05-01", want: true},
+		{suffix: "2024-05-01T123015.123", want: true},
+		{suffix: "bak", want: false},
+		{suffix: "2024-05-01.gz", want: false},
+		{suffix: "2024-05-01T123015", want: false},
+		{suffix: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := isFileRotateSuffix(tt.suffix); got != tt.want {
+			t.Errorf("isFileRotateSuffix(%q) = %v, want %v", tt.suffix, got, tt.want)
+		}
+	}
+}
+
+func TestRotateFileKeepsUnrelatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notifications.jsonl")
+	for _, name := range []string{
+		"notifications.jsonl",
+		"notifications.jsonl.2024-05-01",
+		"notifications.jsonl.2024-05-02T080000.000",
+		"notifications.jsonl.2024-05-03",
+		"notifications.jsonl.bak",
+		"notifications.jsonl.2024-01-01.gz",
+		"other.jsonl.2020-01-01",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rotateFileIfNeeded(path, "2B", 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	rotated, err := rotatedFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 || filepath.Base(rotated[0]) != "notifications.jsonl.2024-05-03" {
+		t.Errorf("rotated files = %v, want newest 2 kept", rotated)
+	}
+	want := []string{filepath.Base(rotated[1]), "notifications.jsonl.2024-01-01.gz", "notifications.jsonl.2024-05-03", "notifications.jsonl.bak", "other.jsonl.2020-01-01"}
+	sort.Strings(want)
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("files after rotation = %v, want %v", names, want)
+	}
+}
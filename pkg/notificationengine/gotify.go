@@ -0,0 +1,65 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+// Message for Gotify API, https://gotify.net/api-docs#/message/createMessage
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+var gotifyPriorities = map[string]int{"info": 2, "warning": 5, "critical": 8}
+
+// Channel is configured like GOTIFY_HOME:::url=https://gotify.example.com:::token=AbCdEf:::click=https://...
+func sendGotifyWithNotification(channel string, fields []string, notification Notification) {
+	settings, _ := channelConfig(fields)
+	serverUrl := strings.TrimSuffix(settings["url"], "/")
+	if len(serverUrl) == 0 || len(settings["token"]) == 0 {
+		slog.Error("GOTIFY - Channel must have url and token configured.", "channel", channel)
+		return
+	}
+
+	message := gotifyMessage{
+		Title:    notification.title(),
+		Message:  notification.Message,
+		Priority: gotifyPriorities[notification.effectiveSeverity()],
+		Extras: map[string]any{
+			"client::display": map[string]string{"contentType": "text/markdown"},
+		},
+	}
+	if value, found := settings["priority"]; found {
+		// Fixed priority of channel (0-10) overrides priority of rule severity
+		if priority, err := strconv.Atoi(value); err == nil {
+			message.Priority = priority
+		}
+	}
+	if settings["markdown"] == "false" {
+		delete(message.Extras, "client::display")
+	}
+	if len(settings["click"]) > 0 {
+		message.Extras["client::notification"] = map[string]any{"click": map[string]string{"url": settings["click"]}}
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("Error encoding gotify message.", "error", err)
+		return
+	}
+	headers := []string{"Content-Type: application/json", "X-Gotify-Key: " + settings["token"]}
+
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("POST", serverUrl+"/message", headers, string(jsonData))
+	if httpStatusCode > 0 && httpStatusCode < 400 {
+		slog.Debug("GOTIFY", "response", responseBody.String())
+		return
+	}
+	slog.Error("GOTIFY", "channel", channel, "status", httpStatusCode, "response", responseBody.String())
+}
@@ -0,0 +1,60 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSendGotify(t *testing.T) {
+	markdown := map[string]any{"contentType": "text/markdown"}
+	tests := []struct {
+		name         string
+		fields       []string
+		notification Notification
+		wantMessage  map[string]any
+	}{
+		{
+			name:         "severity priority",
+			fields:       []string{"token=AbCdEf"},
+			notification: Notification{Device: "plug", RuleID: "power", State: StateFiring, Severity: "critical", Message: "Power is high."},
+			wantMessage: map[string]any{"title": "[ plug ] power firing", "message": "Power is high.", "priority": 8.0,
+				"extras": map[string]any{"client::display": markdown}},
+		},
+		{
+			name:         "resolved is informative",
+			fields:       []string{"token=AbCdEf"},
+			notification: Notification{Device: "plug", RuleID: "power", State: StateResolved, Severity: "critical", Message: "Power is normal."},
+			wantMessage: map[string]any{"title": "[ plug ] power resolved", "message": "Power is normal.", "priority": 2.0,
+				"extras": map[string]any{"client::display": markdown}},
+		},
+		{
+			name:         "fixed priority, plain text and click",
+			fields:       []string{"token=AbCdEf", "priority=10", "markdown=false", "click=https://example.com"},
+			notification: Notification{Device: "plug", State: StateSafety, Message: "Switched off."},
+			wantMessage: map[string]any{"title": "[ plug ] safety", "message": "Switched off.", "priority": 10.0,
+				"extras": map[string]any{"client::notification": map[string]any{"click": map[string]any{"url": "https://example.com"}}}},
+		},
+	}
+	for _, tt := range tests {
+		var gotPath, gotKey string
+		var gotMessage map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath, gotKey = r.URL.Path, r.Header.Get("X-Gotify-Key")
+			if err := json.NewDecoder(r.Body).Decode(&gotMessage); err != nil {
+				t.Errorf("%v: request body is not JSON: %v", tt.name, err)
+			}
+		}))
+		sendGotifyWithNotification("GOTIFY_TEST", append([]string{"url=" + server.URL + "/"}, tt.fields...), tt.notification)
+		server.Close()
+
+		if gotPath != "/message" || gotKey != "AbCdEf" {
+			t.Errorf("%v: request to %q with key %q, want /message with channel token", tt.name, gotPath, gotKey)
+		}
+		if !reflect.DeepEqual(gotMessage, tt.wantMessage) {
+			t.Errorf("%v: message = %v, want %v", tt.name, gotMessage, tt.wantMessage)
+		}
+	}
+}
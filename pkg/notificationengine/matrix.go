@@ -0,0 +1,121 @@
+package notificationengine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+type matrixMessage struct {
+	MsgType       string           `json:"msgtype"`
+	Body          string           `json:"body"`
+	Format        string           `json:"format"`
+	FormattedBody string           `json:"formatted_body"`
+	RelatesTo     *matrixRelatesTo `json:"m.relates_to,omitempty"`
+}
+
+// Reply in thread, https://spec.matrix.org/latest/client-server-api/#threading
+type matrixRelatesTo struct {
+	RelType       string `json:"rel_type"`
+	EventID       string `json:"event_id"`
+	IsFallingBack bool   `json:"is_falling_back"`
+	InReplyTo     struct {
+		EventID string `json:"event_id"`
+	} `json:"m.in_reply_to"`
+}
+
+type matrixResponse struct {
+	EventID    string `json:"event_id"`
+	ErrCode    string `json:"errcode"`
+	Error      string `json:"error"`
+	RetryAfter int64  `json:"retry_after_ms"`
+}
+
+// ThreadRootHandler stores ID of firing message sent by channel for alert of rule on device
+type ThreadRootHandler func(device string, ruleID string, channel string, messageId string)
+
+var threadRootHandler ThreadRootHandler
+
+func SetThreadRootHandler(handler ThreadRootHandler) {
+	threadRootHandler = handler
+}
+
+// Channel is configured like MATRIX_HOME:::url=https://matrix.example.com:::token=syt_xyz:::room=!abcdef:example.com
+func sendMatrixWithNotification(channel string, fields []string, notification Notification) time.Duration {
+	settings, _ := channelConfig(fields)
+	homeserver := strings.TrimSuffix(settings["url"], "/")
+	if len(homeserver) == 0 || len(settings["token"]) == 0 || len(settings["room"]) == 0 {
+		slog.Error("MATRIX - Channel must have url, token and room configured.", "channel", channel)
+		return 0
+	}
+
+	message := matrixMessage{
+		MsgType:       "m.text",
+		Body:          notification.title() + "\n" + notification.Message,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: matrixHtmlBody(notification),
+	}
+	// Following messages of fired alert are replies in its thread
+	if rootEventId := notification.ThreadRoots[channel]; len(rootEventId) > 0 && notification.State != StateFiring {
+		message.RelatesTo = &matrixRelatesTo{RelType: "m.thread", EventID: rootEventId, IsFallingBack: true}
+		message.RelatesTo.InReplyTo.EventID = rootEventId
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("Error encoding matrix message.", "error", err)
+		return 0
+	}
+	// Repeated request with the same transaction ID is not sent to the room again
+	dstUrl := fmt.Sprintf("%v/_matrix/client/v3/rooms/%v/send/m.room.message/%v", homeserver, url.PathEscape(settings["room"]), matrixTransactionId(channel, notification))
+	headers := []string{"Content-Type: application/json", "Authorization: Bearer " + settings["token"]}
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("PUT", dstUrl, headers, string(jsonData))
+
+	var response matrixResponse
+	if responseBody != nil {
+		if err := json.Unmarshal(responseBody.Bytes(), &response); err != nil {
+			slog.Debug("Error decoding matrix response.", "error", err)
+		}
+	}
+	if httpStatusCode <= 0 || httpStatusCode >= 400 {
+		retryAfter := time.Duration(0)
+		if httpStatusCode == 429 {
+			retryAfter = max(time.Duration(response.RetryAfter)*time.Millisecond, time.Second)
+		}
+		slog.Error("MATRIX", "channel", channel, "status", httpStatusCode, "response", responseBody.String(), "retry_after", retryAfter)
+		return retryAfter
+	}
+
+	slog.Debug("MATRIX", "channel", channel, "event_id", response.EventID)
+	if notification.State == StateFiring && len(notification.RuleID) > 0 && len(response.EventID) > 0 && threadRootHandler != nil {
+		threadRootHandler(notification.Device, notification.RuleID, channel, response.EventID)
+	}
+	return 0
+}
+
+func matrixHtmlBody(notification Notification) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "<strong>%v</strong><br/>%v", html.EscapeString(notification.title()), strings.ReplaceAll(html.EscapeString(notification.Message), "\n", "<br/>"))
+	if fields := notificationFields(notification); len(fields) > 0 {
+		body.WriteString("<ul>")
+		for _, field := range fields {
+			fmt.Fprintf(&body, "<li>%v: <code>%v</code></li>", html.EscapeString(field[0]), html.EscapeString(field[1]))
+		}
+		body.WriteString("</ul>")
+	}
+	return body.String()
+}
+
+// Same notification (like retry after rate limit) gets the same transaction ID
+func matrixTransactionId(channel string, notification Notification) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%v/%v/%v/%v/%v/%v", channel, notification.Device, notification.RuleID, notification.State, notification.FiredAt.UnixNano(), notification.Time.UnixNano())))
+	return "tasmota-alerter-" + hex.EncodeToString(hash[:16])
+}
@@ -0,0 +1,120 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatrixThreading(t *testing.T) {
+	var roots []string
+	threadRootHandler = func(device string, ruleID string, channel string, messageId string) {
+		roots = append(roots, fmt.Sprintf("%v/%v/%v/%v", channel, device, ruleID, messageId))
+	}
+	t.Cleanup(func() { threadRootHandler = nil })
+	var requests []matrixMessage
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message matrixMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		requests = append(requests, message)
+		paths = append(paths, r.URL.Path)
+		if strings.Contains(message.Body, "no event") {
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		fmt.Fprintf(w, `{"event_id":"$event%v"}`, len(requests))
+	}))
+	defer server.Close()
+	fields := []string{"url=" + server.URL + "/", "token=syt_xyz", "room=!room:example.com"}
+	threadRoots := map[string]string{"MATRIX_TEST": "$event1"}
+
+	tests := []struct {
+		name         string
+		notification Notification
+		wantThread   string
+		wantRoot     string
+	}{
+		{name: "firing starts thread", notification: Notification{Device: "plug", RuleID: "power", State: StateFiring}, wantRoot: "MATRIX_TEST/plug/power/$event1"},
+		{name: "reminder in thread", notification: Notification{Device: "plug", RuleID: "power", State: StateReminder, ThreadRoots: threadRoots}, wantThread: "$event1"},
+		{name: "alert without thread", notification: Notification{Device: "lamp", RuleID: "power", State: StateReminder}},
+		{name: "thread of other channel", notification: Notification{Device: "plug", RuleID: "power", State: StateReminder, ThreadRoots: map[string]string{"MATRIX_OTHER": "$other"}}},
+		{name: "resolved in thread", notification: Notification{Device: "plug", RuleID: "power", State: StateResolved, ThreadRoots: threadRoots}, wantThread: "$event1"},
+		{name: "firing without event ID", notification: Notification{Device: "plug", RuleID: "power", State: StateFiring, Message: "no event"}},
+		{name: "event without rule", notification: Notification{Device: "plug", State: StateFiring}},
+	}
+	for idx, tt := range tests {
+		roots = nil
+		if retryAfter := sendMatrixWithNotification("MATRIX_TEST", fields, tt.notification); retryAfter != 0 {
+			t.Fatalf("%v: retry after = %v", tt.name, retryAfter)
+		}
+		relatesTo := requests[idx].RelatesTo
+		thread := ""
+		if relatesTo != nil {
+			thread = relatesTo.EventID
+			if relatesTo.RelType != "m.thread" || relatesTo.InReplyTo.EventID != thread {
+				t.Errorf("%v: relation = %+v, want thread reply", tt.name, relatesTo)
+			}
+		}
+		if thread != tt.wantThread {
+			t.Errorf("%v: thread = %q, want %q", tt.name, thread, tt.wantThread)
+		}
+		if root := strings.Join(roots, ","); root != tt.wantRoot {
+			t.Errorf("%v: stored thread root = %q, want %q", tt.name, root, tt.wantRoot)
+		}
+		if !strings.HasPrefix(paths[idx], "/_matrix/client/v3/rooms/!room:example.com/send/m.room.message/tasmota-alerter-") {
+			t.Errorf("%v: request path = %v", tt.name, paths[idx])
+		}
+	}
+}
+
+func TestMatrixRateLimit(t *testing.T) {
+	tests := []struct {
+		body           string
+		wantRetryAfter time.Duration
+	}{
+		{body: `{"errcode":"M_LIMIT_EXCEEDED","retry_after_ms":2500}`, wantRetryAfter: 2500 * time.Millisecond},
+		{body: `{"errcode":"M_LIMIT_EXCEEDED"}`, wantRetryAfter: time.Second},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(tt.body))
+		}))
+		retryAfter := sendMatrixWithNotification("MATRIX_TEST", []string{"url=" + server.URL, "token=syt_xyz", "room=!room:example.com"}, Notification{Device: "plug"})
+		server.Close()
+		if retryAfter != tt.wantRetryAfter {
+			t.Errorf("response %v: retry after = %v, want %v", tt.body, retryAfter, tt.wantRetryAfter)
+		}
+	}
+}
+
+func TestMatrixHtmlBody(t *testing.T) {
+	got := matrixHtmlBody(Notification{Device: "plug<1>", State: StateFiring, Value: "a&b", Message: "Line 1\nLine 2"})
+	want := "<strong>[ plug&lt;1&gt; ] firing</strong><br/>Line 1<br/>Line 2<ul><li>Device: <code>plug&lt;1&gt;</code></li><li>Value: <code>a&amp;b</code></li></ul>"
+	if got != want {
+		t.Errorf("matrixHtmlBody() = %v, want %v", got, want)
+	}
+}
+
+func TestMatrixTransactionId(t *testing.T) {
+	now := time.Now()
+	notification := Notification{Device: "plug", RuleID: "power", State: StateFiring, Time: now}
+	if matrixTransactionId("MATRIX_TEST", notification) != matrixTransactionId("MATRIX_TEST", notification) {
+		t.Error("transaction ID of retried notification changed")
+	}
+	for _, other := range []Notification{
+		{Device: "plug", RuleID: "power", State: StateResolved, Time: now},
+		{Device: "plug", RuleID: "power", State: StateFiring, Time: now.Add(time.Second)},
+	} {
+		if matrixTransactionId("MATRIX_TEST", notification) == matrixTransactionId("MATRIX_TEST", other) {
+			t.Errorf("transaction ID of %+v is the same as of %+v", other, notification)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/jorycz/tasmota-alerter/pkg/mqttclient"
+)
+
+const defaultMqttTopic = "tasmota-alerter/alerts/{device}/{rule}"
+
+// Used to publish notifications of MQTT_ channels, set when connection to broker is ready
+var mqttPublisher *mqttclient.MqttClient
+
+func SetMqttClient(client *mqttclient.MqttClient) {
+	mqttPublisher = client
+}
+
+// Channel is configured like MQTT_NODERED:::topic=tasmota-alerter/alerts/{device}/{rule}:::retain=true:::qos=1
+func publishMqttWithNotification(channel string, fields []string, notification Notification) {
+	if mqttPublisher == nil {
+		slog.Error("MQTT - Client is not available, notification not published.", "channel", channel)
+		return
+	}
+	settings, _ := channelConfig(fields)
+	topic := settings["topic"]
+	if len(topic) == 0 {
+		topic = defaultMqttTopic
+	}
+	topic = mqttTopicOfNotification(topic, notification)
+	qos := 1
+	if value, found := settings["qos"]; found {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 2 {
+			slog.Error("MQTT - Invalid QoS, using default.", "channel", channel, "qos", value, "default", qos)
+		} else {
+			qos = parsed
+		}
+	}
+	retain := settings["retain"] == "true"
+
+	jsonData, err := json.Marshal(notification)
+	if err != nil {
+		slog.Error("Error encoding MQTT notification.", "error", err)
+		return
+	}
+	// Notifications are sent while processing MQTT messages, waiting for delivery there would block the client
+	mqttPublisher.PublishAsync(topic, byte(qos), retain, jsonData)
+	slog.Debug("MQTT", "channel", channel, "topic", topic, "retain", retain, "qos", qos)
+}
+
+// Replace {device}, {rule} and {state} in topic. Notifications without device (like reports) use "tasmota-alerter" and state as rule.
+func mqttTopicOfNotification(topic string, notification Notification) string {
+	device := notification.Device
+	if len(device) == 0 {
+		device = "tasmota-alerter"
+	}
+	rule := notification.RuleID
+	if len(rule) == 0 {
+		rule = notification.State
+	}
+	return strings.NewReplacer(
+		"{device}", mqttTopicLevel(device),
+		"{rule}", mqttTopicLevel(rule),
+		"{state}", mqttTopicLevel(notification.State),
+	).Replace(topic)
+}
+
+// Rule ID like ENERGY-->Power>1500 can't contain level separator nor wildcards
+func mqttTopicLevel(value string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(value)
+}
@@ -0,0 +1,48 @@
+package notificationengine
+
+import "testing"
+
+func TestMqttTopicOfNotification(t *testing.T) {
+	tests := []struct {
+		name         string
+		topic        string
+		notification Notification
+		want         string
+	}{
+		{
+			name:         "default topic",
+			topic:        defaultMqttTopic,
+			notification: Notification{Device: "plug", RuleID: "power", State: StateFiring},
+			want:         "tasmota-alerter/alerts/plug/power",
+		},
+		{
+			name:         "state level",
+			topic:        "home/{device}/{rule}/{state}",
+			notification: Notification{Device: "plug", RuleID: "power", State: StateResolved},
+			want:         "home/plug/power/resolved",
+		},
+		{
+			name:         "default rule ID with separators",
+			topic:        defaultMqttTopic,
+			notification: Notification{Device: "plug", RuleID: "ENERGY-->Power>1500/2+#", State: StateFiring},
+			want:         "tasmota-alerter/alerts/plug/ENERGY-->Power>1500_2__",
+		},
+		{
+			name:         "report without device and rule",
+			topic:        defaultMqttTopic,
+			notification: Notification{State: StateReport},
+			want:         "tasmota-alerter/alerts/tasmota-alerter/report",
+		},
+		{
+			name:         "fixed topic",
+			topic:        "home/alerts",
+			notification: Notification{Device: "plug", RuleID: "power"},
+			want:         "home/alerts",
+		},
+	}
+	for _, tt := range tests {
+		if got := mqttTopicOfNotification(tt.topic, tt.notification); got != tt.want {
+			t.Errorf("%v: mqttTopicOfNotification() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package notificationengine
+
+import (
+	"fmt"
+	"time"
+)
+
+// States of notification
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+	StateReminder = "reminder"
+	StateEvent    = "event"
+	StateFlapping = "flapping"
+	StateStable   = "stable"
+	StateAction   = "action"
+	StateSafety   = "safety"
+	StateReport   = "report"
+)
+
+// Notification is a message for notification channels together with details about alert which caused it
+type Notification struct {
+	Device    string    `json:"device"`
+	RuleID    string    `json:"rule"`
+	State     string    `json:"state"`
+	Value     string    `json:"value"`
+	Condition string    `json:"condition"`
+	Severity  string    `json:"severity"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+	// Zero when alert was not fired (events, reports) or is not resolved yet
+	FiredAt    time.Time `json:"fired_at"`
+	ResolvedAt time.Time `json:"resolved_at"`
+	// Message IDs of firing notification by channel, following notifications of the alert are replies to it (like Matrix thread)
+	ThreadRoots map[string]string `json:"-"`
+}
+
+// Short title for channels which show title separately from message, like push notifications
+func (n Notification) title() string {
+	switch {
+	case len(n.Device) > 0 && len(n.RuleID) > 0:
+		return fmt.Sprintf("[ %v ] %v %v", n.Device, n.RuleID, n.State)
+	case len(n.Device) > 0:
+		return fmt.Sprintf("[ %v ] %v", n.Device, n.State)
+	case n.State == StateReport:
+		return "Tasmota alerter report"
+	}
+	return "Tasmota alerter"
+}
+
+// Resolved alerts and reports are informative regardless of rule severity
+func (n Notification) effectiveSeverity() string {
+	if n.State == StateResolved || n.State == StateStable || n.State == StateReport || len(n.Severity) == 0 {
+		return "info"
+	}
+	return n.Severity
+}
+
+// Shorten text to maximum count of characters, end of shortened text is marked by …
+func truncate(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	if maxChars < 1 {
+		return ""
+	}
+	return string(runes[:maxChars-1]) + "…"
+}
+
+// Name and value pairs of alert details for channels which show them as separate fields
+func notificationFields(n Notification) [][2]string {
+	var fields [][2]string
+	if len(n.Device) > 0 {
+		fields = append(fields, [2]string{"Device", n.Device})
+	}
+	if len(n.Value) > 0 {
+		fields = append(fields, [2]string{"Value", n.Value})
+	}
+	if len(n.Condition) > 0 {
+		fields = append(fields, [2]string{"Condition", n.Condition})
+	}
+	if len(n.RuleID) > 0 && len(n.Severity) > 0 {
+		fields = append(fields, [2]string{"Severity", n.Severity})
+	}
+	return fields
+}
+
+// Hex color of state for channels with colored messages (red when firing, green when resolved)
+func (n Notification) color() string {
+	switch n.State {
+	case StateFiring, StateSafety:
+		return "#d00000"
+	case StateResolved, StateStable:
+		return "#2eb886"
+	case StateReminder, StateFlapping:
+		return "#daa038"
+	}
+	return "#808080"
+}
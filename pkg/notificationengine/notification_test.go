@@ -0,0 +1,119 @@
+package notificationengine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Load notification channels like from configuration files, channels are removed after test
+func loadTestChannels(t *testing.T, lines ...string) {
+	t.Helper()
+	lock.Lock()
+	if notificationChannels == nil {
+		notificationChannels = map[string][]string{}
+		notificationChannelOptions = map[string]channelOptions{}
+	}
+	lock.Unlock()
+	t.Cleanup(func() { createUniversalRuleSet(nil) })
+	createUniversalRuleSet(lines)
+}
+
+func TestNotificationTitle(t *testing.T) {
+	tests := []struct {
+		notification Notification
+		want         string
+	}{
+		{notification: Notification{Device: "plug", RuleID: "power", State: StateFiring}, want: "[ plug ] power firing"},
+		{notification: Notification{Device: "plug", State: StateSafety}, want: "[ plug ] safety"},
+		{notification: Notification{State: StateReport}, want: "Tasmota alerter report"},
+		{notification: Notification{}, want: "Tasmota alerter"},
+	}
+	for _, tt := range tests {
+		if got := tt.notification.title(); got != tt.want {
+			t.Errorf("title() of %+v = %q, want %q", tt.notification, got, tt.want)
+		}
+	}
+}
+
+func TestEffectiveSeverity(t *testing.T) {
+	tests := []struct {
+		state    string
+		severity string
+		want     string
+	}{
+		{state: StateFiring, severity: "critical", want: "critical"},
+		{state: StateReminder, severity: "warning", want: "warning"},
+		{state: StateFiring, severity: "", want: "info"},
+		{state: StateResolved, severity: "critical", want: "info"},
+		{state: StateStable, severity: "critical", want: "info"},
+		{state: StateReport, severity: "warning", want: "info"},
+	}
+	for _, tt := range tests {
+		if got := (Notification{State: tt.state, Severity: tt.severity}).effectiveSeverity(); got != tt.want {
+			t.Errorf("effectiveSeverity() of %v %v = %v, want %v", tt.state, tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestNotificationFields(t *testing.T) {
+	tests := []struct {
+		notification Notification
+		want         [][2]string
+	}{
+		{
+			notification: Notification{Device: "plug", RuleID: "power", Value: "2500", Condition: ">2000", Severity: "critical"},
+			want:         [][2]string{{"Device", "plug"}, {"Value", "2500"}, {"Condition", ">2000"}, {"Severity", "critical"}},
+		},
+		{notification: Notification{Device: "plug", Severity: "critical"}, want: [][2]string{{"Device", "plug"}}},
+		{notification: Notification{}, want: nil},
+	}
+	for _, tt := range tests {
+		if got := notificationFields(tt.notification); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("notificationFields(%+v) = %v, want %v", tt.notification, got, tt.want)
+		}
+	}
+}
+
+func TestNotificationColor(t *testing.T) {
+	tests := []struct {
+		state    string
+		severity string
+		want     string
+	}{
+		{state: StateFiring, severity: "info", want: "#d00000"},
+		{state: StateFiring, severity: "critical", want: "#d00000"},
+		{state: StateSafety, severity: "", want: "#d00000"},
+		{state: StateResolved, severity: "critical", want: "#2eb886"},
+		{state: StateStable, severity: "warning", want: "#2eb886"},
+		{state: StateReminder, severity: "info", want: "#daa038"},
+		{state: StateFlapping, severity: "warning", want: "#daa038"},
+		{state: StateReport, severity: "", want: "#808080"},
+		{state: "", severity: "", want: "#808080"},
+	}
+	for _, tt := range tests {
+		n := Notification{State: tt.state, Severity: tt.severity}
+		if got := n.color(); got != tt.want {
+			t.Errorf("color() of %q with severity %q = %v, want %v", tt.state, tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxChars int
+		want     string
+	}{
+		{text: "short", maxChars: 10, want: "short"},
+		{text: "exact", maxChars: 5, want: "exact"},
+		{text: "too long", maxChars: 5, want: "too …"},
+		{text: "žluťoučký kůň", maxChars: 6, want: "žluťo…"},
+		{text: "text", maxChars: 0, want: ""},
+		{text: "text", maxChars: -5, want: ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.text, tt.maxChars); got != tt.want {
+			t.Errorf("truncate(%q, %v) = %q, want %q", tt.text, tt.maxChars, got, tt.want)
+		}
+	}
+}
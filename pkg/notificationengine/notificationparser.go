@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jorycz/tasmota-alerter/pkg/utils"
 )
@@ -18,26 +19,89 @@ var (
 func SetupChannels(smtp string) {
 	smtpSendingServer = smtp
 	notificationChannels = make(map[string][]string)
+	notificationChannelOptions = make(map[string]channelOptions)
 	readConfigFiles()
 }
 
-func NotifyChannels(channels string, message string) {
+func NotifyChannels(channels string, notification Notification) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
 	notifyChannels := strings.Split(channels, ",")
 	for _, channel := range notifyChannels {
-
-		if strings.HasPrefix(channel, "EMAIL") {
-			sendEmailWithMessage(notificationChannels[channel], message)
-		}
-		if strings.HasPrefix(channel, "TELEGRAM") {
-			sendTelegramWithMessage(notificationChannels[channel], message)
+		if options := optionsForChannel(channel); options.Digest > 0 {
+			addToDigest(channel, options.Digest, notification)
+			continue
 		}
+		submitToChannel(channel, notification)
+	}
+}
+
+// Returns duration after which the notification should be sent again when channel service asks for it
+func sendToChannel(channel string, notification Notification) time.Duration {
+	if strings.HasPrefix(channel, "EMAIL") {
+		sendEmailWithMessage(notificationChannels[channel], notification.Message)
+	}
+	if strings.HasPrefix(channel, "TELEGRAM") {
+		return sendTelegramWithMessage(notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "EXEC") {
+		runExecWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "WEBHOOK") {
+		sendWebhookWithNotification(channel, notification)
+	}
+	if strings.HasPrefix(channel, "NTFY") {
+		return sendNtfyWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "GOTIFY") {
+		sendGotifyWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "PUSHOVER") {
+		return sendPushoverWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "SLACK") || strings.HasPrefix(channel, "MATTERMOST") {
+		return sendSlackWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "DISCORD") {
+		return sendDiscordWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "MATRIX") {
+		return sendMatrixWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "TEAMS") {
+		return sendTeamsWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "MQTT") {
+		publishMqttWithNotification(channel, notificationChannels[channel], notification)
 	}
+	if strings.HasPrefix(channel, "SYSLOG") {
+		sendSyslogWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "FILE") {
+		writeFileWithNotification(channel, notificationChannels[channel], notification)
+	}
+	if strings.HasPrefix(channel, "SMS") {
+		sendSmsWithNotification(channel, notification)
+	}
+	if strings.HasPrefix(channel, "SIGNAL") {
+		sendSignalWithNotification(channel, notificationChannels[channel], notification)
+	}
+	return 0
+}
+
+// Channels can be removed from configuration while alerts notified by them are still active
+func ChannelExists(channel string) bool {
+	lock.Lock()
+	defer lock.Unlock()
+	_, found := notificationChannels[channel]
+	return found
 }
 
 func readConfigFiles() {
 	ruleFilesLines, err := utils.ReadFilesWithSuffix("notifications", ".conf")
 	if err != nil {
-		slog.Error("Error when reading RULE FILES", err)
+		slog.Error("Error when reading RULE FILES", "error", err)
 	}
 	createUniversalRuleSet(ruleFilesLines)
 }
@@ -47,6 +111,15 @@ func createUniversalRuleSet(ruleLines []string) {
 	for k := range notificationChannels {
 		delete(notificationChannels, k)
 	}
+	for k := range notificationChannelOptions {
+		delete(notificationChannelOptions, k)
+	}
+	for k := range webhookConfigs {
+		delete(webhookConfigs, k)
+	}
+	for k := range smsChannels {
+		delete(smsChannels, k)
+	}
 	lock.Unlock()
 
 	rulesProcessed = incrementSeqNumber()
@@ -54,9 +127,21 @@ func createUniversalRuleSet(ruleLines []string) {
 	for _, line := range ruleLines {
 		slog.Debug("Loading notification rule.", "data", line)
 		parsed := strings.Split(line, ":::")
+		if parsed[0] == channelOptionsTag {
+			parseChannelOptions(line, parsed)
+			continue
+		}
 		if len(parsed) > 1 {
 			notificationChannels[parsed[0]] = parsed[1:]
 			slog.Debug("CHANNEL", "line", parsed)
+			lock.Lock()
+			if strings.HasPrefix(parsed[0], "WEBHOOK") {
+				loadWebhookChannel(parsed[0], parsed[1:])
+			}
+			if strings.HasPrefix(parsed[0], "SMS") {
+				loadSmsChannel(parsed[0], parsed[1:])
+			}
+			lock.Unlock()
 			_ = rulesProcessed()
 		} else {
 			slog.Error("Can not parse notification!", "notification_line", line)
@@ -70,8 +155,32 @@ func sendEmailWithMessage(recipients []string, message string) {
 	sendEmailMessage(smtpSendingServer, recipients, message)
 }
 
-func sendTelegramWithMessage(botTokenAndChatId []string, message string) {
-	sendTelegramMessage(botTokenAndChatId[0], botTokenAndChatId[1], message)
+func sendTelegramWithMessage(botTokenAndChatId []string, notification Notification) time.Duration {
+	messageId, retryAfter := sendTelegramMessage(botTokenAndChatId[0], botTokenAndChatId[1], notification.Message)
+	switch notification.State {
+	case StateFiring, StateReminder:
+		if messageId > 0 && len(notification.RuleID) > 0 {
+			rememberTelegramAlertMessage(botTokenAndChatId[1], messageId, notification)
+		}
+	case StateResolved:
+		forgetTelegramAlertMessages(notification.Device, notification.RuleID)
+	}
+	return retryAfter
+}
+
+// Channel fields like key=value are settings, other fields are values (like list of recipients)
+func channelConfig(fields []string) (map[string]string, []string) {
+	settings := map[string]string{}
+	var values []string
+	for _, field := range fields {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			values = append(values, strings.TrimSpace(field))
+			continue
+		}
+		settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return settings, values
 }
 
 func incrementSeqNumber() func() int {
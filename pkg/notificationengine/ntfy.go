@@ -0,0 +1,104 @@
+package notificationengine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+// Message published to ntfy server root URL in JSON format, https://docs.ntfy.sh/publish/#publish-as-json
+type ntfyMessage struct {
+	Topic    string       `json:"topic"`
+	Title    string       `json:"title,omitempty"`
+	Message  string       `json:"message"`
+	Priority int          `json:"priority,omitempty"`
+	Tags     []string     `json:"tags,omitempty"`
+	Click    string       `json:"click,omitempty"`
+	Actions  []ntfyAction `json:"actions,omitempty"`
+}
+
+type ntfyAction struct {
+	Action string `json:"action"`
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+}
+
+var ntfyPriorities = map[string]int{"info": 3, "warning": 4, "critical": 5}
+
+// Channel is configured like NTFY_PHONE:::url=https://ntfy.example.com:::topic=alerts:::token=tk_xyz:::tags=house:::click=https://...:::action=Open|https://...
+func sendNtfyWithNotification(channel string, fields []string, notification Notification) time.Duration {
+	settings, _ := channelConfig(fields)
+	serverUrl := strings.TrimSuffix(settings["url"], "/")
+	if len(serverUrl) == 0 || len(settings["topic"]) == 0 {
+		slog.Error("NTFY - Channel must have url and topic configured.", "channel", channel)
+		return 0
+	}
+
+	message := ntfyMessage{
+		Topic:    settings["topic"],
+		Title:    notification.title(),
+		Message:  notification.Message,
+		Priority: ntfyPriorities[notification.effectiveSeverity()],
+		Click:    settings["click"],
+	}
+	if value, found := settings["priority"]; found {
+		// Fixed priority of channel (1-5) overrides priority of rule severity
+		if priority, err := strconv.Atoi(value); err == nil {
+			message.Priority = priority
+		}
+	}
+	if len(settings["tags"]) > 0 {
+		message.Tags = strings.Split(settings["tags"], ",")
+	}
+	message.Tags = append(message.Tags, ntfyStateTag(notification))
+	// Only one action can be set in configuration, format is Label|URL
+	if label, actionUrl, found := strings.Cut(settings["action"], "|"); found {
+		message.Actions = []ntfyAction{{Action: "view", Label: label, URL: actionUrl}}
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("Error encoding ntfy message.", "error", err)
+		return 0
+	}
+	headers := []string{"Content-Type: application/json"}
+	switch {
+	case len(settings["token"]) > 0:
+		headers = append(headers, "Authorization: Bearer "+settings["token"])
+	case len(settings["user"]) > 0:
+		credentials := base64.StdEncoding.EncodeToString([]byte(settings["user"] + ":" + settings["password"]))
+		headers = append(headers, "Authorization: Basic "+credentials)
+	}
+
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("POST", serverUrl, headers, string(jsonData))
+	if httpStatusCode > 0 && httpStatusCode < 400 {
+		slog.Debug("NTFY", "response", responseBody.String())
+		return 0
+	}
+	slog.Error("NTFY", "channel", channel, "status", httpStatusCode, "response", responseBody.String())
+	if httpStatusCode == 429 {
+		// ntfy does not tell how long to wait, visitor request limit is refilled every few seconds
+		return 10 * time.Second
+	}
+	return 0
+}
+
+// Emoji tag shown in front of title, https://docs.ntfy.sh/emojis/
+func ntfyStateTag(notification Notification) string {
+	switch {
+	case notification.State == StateResolved || notification.State == StateStable:
+		return "white_check_mark"
+	case notification.State == StateReport:
+		return "bar_chart"
+	case notification.effectiveSeverity() == "critical":
+		return "rotating_light"
+	case notification.effectiveSeverity() == "warning":
+		return "warning"
+	}
+	return "information_source"
+}
@@ -0,0 +1,172 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+const (
+	pushoverApiUrl = "https://api.pushover.net/1"
+	// Emergency notification is repeated every retry until it is acknowledged or expired
+	pushoverEmergencyPriority = 2
+	defaultPushoverRetry      = time.Minute
+	defaultPushoverExpire     = time.Hour
+	// Limits of emergency notification accepted by Pushover API
+	minPushoverRetry  = 30 * time.Second
+	maxPushoverExpire = 3 * time.Hour
+	// Used when monthly limit is reached and API does not tell when it is reset
+	defaultPushoverLimitPause = time.Hour
+)
+
+// ReceiptHandler stores receipt of emergency notification sent by channel for alert of rule on device
+type ReceiptHandler func(device string, ruleID string, channel string, receipt string)
+
+type pushoverResponse struct {
+	Status  int      `json:"status"`
+	Receipt string   `json:"receipt"`
+	Errors  []string `json:"errors"`
+}
+
+type pushoverReceiptResponse struct {
+	Status               int    `json:"status"`
+	Acknowledged         int    `json:"acknowledged"`
+	AcknowledgedBy       string `json:"acknowledged_by"`
+	AcknowledgedByDevice string `json:"acknowledged_by_device"`
+	Expired              int    `json:"expired"`
+}
+
+var (
+	pushoverPriorities = map[string]int{"info": 0, "warning": 1, "critical": pushoverEmergencyPriority}
+	receiptHandler     ReceiptHandler
+)
+
+func SetReceiptHandler(handler ReceiptHandler) {
+	receiptHandler = handler
+}
+
+// Channel is configured like PUSHOVER_PHONE:::user=uQiRzpo4DXgh:::token=azGDORePK8gMaC:::device=iphone:::sound=siren:::retry=60s:::expire=1h
+func sendPushoverWithNotification(channel string, fields []string, notification Notification) time.Duration {
+	settings, _ := channelConfig(fields)
+	if len(settings["user"]) == 0 || len(settings["token"]) == 0 {
+		slog.Error("PUSHOVER - Channel must have user and token configured.", "channel", channel)
+		return 0
+	}
+
+	priority := pushoverPriorities[notification.effectiveSeverity()]
+	form := url.Values{}
+	form.Set("token", settings["token"])
+	form.Set("user", settings["user"])
+	form.Set("title", notification.title())
+	form.Set("message", notification.Message)
+	form.Set("priority", strconv.Itoa(priority))
+	form.Set("timestamp", strconv.FormatInt(notification.Time.Unix(), 10))
+	if len(settings["device"]) > 0 {
+		form.Set("device", settings["device"])
+	}
+	if len(settings["sound"]) > 0 {
+		form.Set("sound", settings["sound"])
+	}
+	if priority == pushoverEmergencyPriority {
+		retry := max(durationSetting(channel, settings, "retry", defaultPushoverRetry), minPushoverRetry)
+		expire := min(durationSetting(channel, settings, "expire", defaultPushoverExpire), maxPushoverExpire)
+		form.Set("retry", strconv.Itoa(int(retry.Seconds())))
+		form.Set("expire", strconv.Itoa(int(expire.Seconds())))
+	}
+
+	httpStatusCode, responseBody, responseHeaders := http.CallUrlWithResponseHeaders("POST", pushoverApiUrl+"/messages.json", []string{"Content-Type: application/x-www-form-urlencoded"}, form.Encode())
+	// Monthly message limit of application is reset at X-Limit-App-Reset (unix time)
+	limitResetIn := defaultPushoverLimitPause
+	if reset, err := strconv.ParseInt(responseHeaders.Get("X-Limit-App-Reset"), 10, 64); err == nil {
+		limitResetIn = max(time.Until(time.Unix(reset, 0)), time.Second)
+	}
+	if httpStatusCode <= 0 || httpStatusCode >= 400 {
+		slog.Error("PUSHOVER", "channel", channel, "status", httpStatusCode, "response", responseBody.String())
+		if httpStatusCode == 429 {
+			// Monthly message limit is reached, do not try again until it is reset
+			return limitResetIn
+		}
+		return 0
+	}
+	if responseHeaders.Get("X-Limit-App-Remaining") == "0" {
+		pauseChannel(channel, limitResetIn)
+	}
+
+	var response pushoverResponse
+	if err := json.NewDecoder(responseBody).Decode(&response); err != nil {
+		slog.Error("Error decoding pushover response.", "error", err)
+		return 0
+	}
+	if response.Status != 1 {
+		slog.Error("PUSHOVER", "channel", channel, "errors", response.Errors)
+		return 0
+	}
+	slog.Debug("PUSHOVER", "channel", channel, "receipt", response.Receipt)
+	if len(response.Receipt) > 0 && len(notification.RuleID) > 0 && receiptHandler != nil {
+		// Notification can be sent while alerts are locked, receipt is stored later
+		go receiptHandler(notification.Device, notification.RuleID, channel, response.Receipt)
+	}
+	return 0
+}
+
+// Returns user who acknowledged emergency notification and whether receipt is still valid (not acknowledged nor expired)
+func PushoverReceiptStatus(channel string, receipt string) (acknowledgedBy string, pending bool, err error) {
+	settings, _ := channelConfig(channelFields(channel))
+	dstUrl := fmt.Sprintf("%v/receipts/%v.json?token=%v", pushoverApiUrl, url.PathEscape(receipt), url.QueryEscape(settings["token"]))
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("GET", dstUrl, nil, "")
+	if httpStatusCode <= 0 || httpStatusCode >= 400 {
+		return "", true, fmt.Errorf("pushover receipt request failed with status %v: %v", httpStatusCode, responseBody.String())
+	}
+
+	var response pushoverReceiptResponse
+	if err := json.NewDecoder(responseBody).Decode(&response); err != nil {
+		return "", true, fmt.Errorf("can't decode pushover receipt: %w", err)
+	}
+	if response.Acknowledged == 1 {
+		user := "pushover:" + response.AcknowledgedBy
+		if len(response.AcknowledgedByDevice) > 0 {
+			user = "pushover:" + response.AcknowledgedByDevice
+		}
+		return user, false, nil
+	}
+	return "", response.Expired != 1, nil
+}
+
+// Stop repeating of emergency notification, like when alert is resolved or acknowledged elsewhere
+func CancelPushoverReceipt(channel string, receipt string) {
+	settings, _ := channelConfig(channelFields(channel))
+	dstUrl := fmt.Sprintf("%v/receipts/%v/cancel.json", pushoverApiUrl, url.PathEscape(receipt))
+	form := url.Values{}
+	form.Set("token", settings["token"])
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("POST", dstUrl, []string{"Content-Type: application/x-www-form-urlencoded"}, form.Encode())
+	if httpStatusCode <= 0 || httpStatusCode >= 400 {
+		slog.Error("PUSHOVER - Can not cancel receipt.", "channel", channel, "status", httpStatusCode, "response", responseBody.String())
+		return
+	}
+	slog.Debug("PUSHOVER - Receipt cancelled.", "channel", channel, "receipt", receipt)
+}
+
+func channelFields(channel string) []string {
+	lock.Lock()
+	defer lock.Unlock()
+	return notificationChannels[channel]
+}
+
+func durationSetting(channel string, settings map[string]string, key string, defaultValue time.Duration) time.Duration {
+	value, found := settings[key]
+	if !found {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || duration <= 0 {
+		slog.Error("Invalid duration in channel configuration, using default.", "channel", channel, "key", key, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return duration
+}
@@ -0,0 +1,36 @@
+package notificationengine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationSetting(t *testing.T) {
+	tests := []struct {
+		settings map[string]string
+		want     time.Duration
+	}{
+		{settings: map[string]string{}, want: time.Minute},
+		{settings: map[string]string{"retry": "30s"}, want: 30 * time.Second},
+		{settings: map[string]string{"retry": " 2m "}, want: 2 * time.Minute},
+		{settings: map[string]string{"retry": "soon"}, want: time.Minute},
+		{settings: map[string]string{"retry": "-5s"}, want: time.Minute},
+		{settings: map[string]string{"retry": "0s"}, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := durationSetting("PUSHOVER_TEST", tt.settings, "retry", time.Minute); got != tt.want {
+			t.Errorf("durationSetting(%v) = %v, want %v", tt.settings, got, tt.want)
+		}
+	}
+}
+
+func TestChannelExists(t *testing.T) {
+	loadTestChannels(t, "PUSHOVER_PHONE:::user=u:::token=t")
+
+	if !ChannelExists("PUSHOVER_PHONE") {
+		t.Errorf("configured channel does not exist")
+	}
+	if ChannelExists("PUSHOVER_TABLET") {
+		t.Errorf("channel which is not configured exists")
+	}
+}
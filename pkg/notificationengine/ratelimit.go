@@ -0,0 +1,174 @@
+package notificationengine
+
+import (
+	"fmt"
+	"log/slog"
+	nethttp "net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRateLimitQueue = 10
+	minRateLimitWait      = 100 * time.Millisecond
+)
+
+// Token bucket of channel. Notifications over the limit are queued, notifications over the queue size are collapsed into summary.
+// Channel can be blocked also by the service itself, like telegram retry_after.
+type channelLimiter struct {
+	tokens       float64
+	lastRefill   time.Time
+	blockedUntil time.Time
+	queue        []Notification
+	suppressed   int
+	draining     bool
+}
+
+var (
+	limiters     = map[string]*channelLimiter{}
+	limitersLock sync.Mutex
+)
+
+// Send notification to channel respecting its rate limit
+func submitToChannel(channel string, notification Notification) {
+	options := optionsForChannel(channel)
+
+	limitersLock.Lock()
+	limiter := limiters[channel]
+	if limiter == nil {
+		limiter = &channelLimiter{tokens: float64(options.burst()), lastRefill: time.Now()}
+		limiters[channel] = limiter
+	}
+	if len(limiter.queue) == 0 && limiter.take(options, time.Now()) {
+		limitersLock.Unlock()
+		limiter.send(channel, notification)
+		return
+	}
+
+	queueSize := options.Queue
+	if queueSize == 0 {
+		queueSize = defaultRateLimitQueue
+	}
+	if len(limiter.queue) < queueSize {
+		slog.Debug("RATE LIMIT - Notification queued.", "channel", channel, "queued", len(limiter.queue)+1)
+		limiter.queue = append(limiter.queue, notification)
+	} else {
+		limiter.suppressed++
+		slog.Warn("RATE LIMIT - Notification suppressed.", "channel", channel, "suppressed", limiter.suppressed)
+	}
+	limiter.startDraining(channel)
+	limitersLock.Unlock()
+}
+
+// Must be called with limitersLock held
+func (l *channelLimiter) take(options channelOptions, now time.Time) bool {
+	if now.Before(l.blockedUntil) {
+		return false
+	}
+	if options.Rate == 0 {
+		return true
+	}
+	l.tokens += now.Sub(l.lastRefill).Seconds() / options.RateInterval.Seconds() * float64(options.Rate)
+	l.lastRefill = now
+	if l.tokens > float64(options.burst()) {
+		l.tokens = float64(options.burst())
+	}
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+	return false
+}
+
+// Must be called with limitersLock held
+func (l *channelLimiter) nextTokenIn(options channelOptions, now time.Time) time.Duration {
+	wait := minRateLimitWait
+	if now.Before(l.blockedUntil) {
+		wait = l.blockedUntil.Sub(now)
+	} else if options.Rate > 0 {
+		wait = time.Duration((1 - l.tokens) * float64(options.RateInterval) / float64(options.Rate))
+	}
+	return max(wait, minRateLimitWait)
+}
+
+// Block channel without resending the notification, like when service tells that no more requests are allowed for a while
+func pauseChannel(channel string, duration time.Duration) {
+	options := optionsForChannel(channel)
+
+	limitersLock.Lock()
+	defer limitersLock.Unlock()
+	limiter := limiters[channel]
+	if limiter == nil {
+		limiter = &channelLimiter{tokens: float64(options.burst()), lastRefill: time.Now()}
+		limiters[channel] = limiter
+	}
+	if until := time.Now().Add(duration); until.After(limiter.blockedUntil) {
+		limiter.blockedUntil = until
+	}
+}
+
+func (l *channelLimiter) send(channel string, notification Notification) {
+	retryAfter := sendToChannel(channel, notification)
+	if retryAfter <= 0 {
+		return
+	}
+	slog.Warn("RATE LIMIT - Channel asked to retry later.", "channel", channel, "retry_after", retryAfter)
+	limitersLock.Lock()
+	defer limitersLock.Unlock()
+	l.blockedUntil = time.Now().Add(retryAfter)
+	l.queue = append([]Notification{notification}, l.queue...)
+	l.startDraining(channel)
+}
+
+// Must be called with limitersLock held
+func (l *channelLimiter) startDraining(channel string) {
+	if l.draining {
+		return
+	}
+	l.draining = true
+	go l.drain(channel)
+}
+
+func (l *channelLimiter) drain(channel string) {
+	for {
+		options := optionsForChannel(channel)
+		now := time.Now()
+
+		limitersLock.Lock()
+		if len(l.queue) == 0 && l.suppressed == 0 {
+			l.draining = false
+			limitersLock.Unlock()
+			return
+		}
+		if !l.take(options, now) {
+			wait := l.nextTokenIn(options, now)
+			limitersLock.Unlock()
+			time.Sleep(wait)
+			continue
+		}
+		var notification Notification
+		if len(l.queue) > 0 {
+			notification = l.queue[0]
+			l.queue = l.queue[1:]
+		} else {
+			notification = Notification{Message: fmt.Sprintf("%v more notifications were suppressed by rate limit.", l.suppressed), Time: now}
+			l.suppressed = 0
+		}
+		limitersLock.Unlock()
+
+		l.send(channel, notification)
+	}
+}
+
+// Retry-After header of 429 response in seconds or as HTTP date, defaultValue when it is missing
+func retryAfterHeader(headers nethttp.Header, defaultValue time.Duration) time.Duration {
+	value := headers.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := nethttp.ParseTime(value); err == nil {
+		return max(time.Until(at), time.Second)
+	}
+	return defaultValue
+}
@@ -0,0 +1,110 @@
+package notificationengine
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChannelLimiterTake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		options channelOptions
+		// Offsets from start when token is requested
+		requests []time.Duration
+		want     []bool
+	}{
+		{
+			name:     "no rate limit",
+			options:  channelOptions{},
+			requests: []time.Duration{0, 0, 0},
+			want:     []bool{true, true, true},
+		},
+		{
+			name:     "burst defaults to rate",
+			options:  channelOptions{Rate: 2, RateInterval: time.Minute},
+			requests: []time.Duration{0, 0, 0},
+			want:     []bool{true, true, false},
+		},
+		{
+			name:     "token is refilled after interval divided by rate",
+			options:  channelOptions{Rate: 2, RateInterval: time.Minute},
+			requests: []time.Duration{0, 0, 29 * time.Second, 30 * time.Second, 31 * time.Second},
+			want:     []bool{true, true, false, true, false},
+		},
+		{
+			name:     "refill is capped by burst",
+			options:  channelOptions{Rate: 1, RateInterval: time.Second, Burst: 3},
+			requests: []time.Duration{0, 0, 0, 0, time.Hour, time.Hour, time.Hour, time.Hour},
+			want:     []bool{true, true, true, false, true, true, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := &channelLimiter{tokens: float64(tt.options.burst()), lastRefill: start}
+			for i, offset := range tt.requests {
+				if got := limiter.take(tt.options, start.Add(offset)); got != tt.want[i] {
+					t.Errorf("request %v at %v: take() = %v, want %v", i, offset, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChannelLimiterBlocked(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := &channelLimiter{lastRefill: now, blockedUntil: now.Add(time.Minute)}
+	options := channelOptions{}
+	if limiter.take(options, now) {
+		t.Error("take() of blocked channel = true, want false")
+	}
+	if got := limiter.nextTokenIn(options, now); got != time.Minute {
+		t.Errorf("nextTokenIn() = %v, want %v", got, time.Minute)
+	}
+	if !limiter.take(options, now.Add(time.Minute)) {
+		t.Error("take() after block = false, want true")
+	}
+}
+
+func TestChannelLimiterNextTokenIn(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		tokens  float64
+		options channelOptions
+		want    time.Duration
+	}{
+		{tokens: 0, options: channelOptions{Rate: 2, RateInterval: time.Minute}, want: 30 * time.Second},
+		{tokens: 0.5, options: channelOptions{Rate: 2, RateInterval: time.Minute}, want: 15 * time.Second},
+		{tokens: 0.999999, options: channelOptions{Rate: 1, RateInterval: time.Second}, want: minRateLimitWait},
+		{tokens: 0, options: channelOptions{}, want: minRateLimitWait},
+	}
+	for _, tt := range tests {
+		limiter := &channelLimiter{tokens: tt.tokens, lastRefill: now}
+		if got := limiter.nextTokenIn(tt.options, now); got != tt.want {
+			t.Errorf("nextTokenIn() with %v tokens and %+v = %v, want %v", tt.tokens, tt.options, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 5 * time.Second},
+		{value: "120", want: 2 * time.Minute},
+		{value: "0", want: 5 * time.Second},
+		{value: "soon", want: 5 * time.Second},
+		{value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: time.Second},
+	}
+	for _, tt := range tests {
+		headers := http.Header{}
+		if len(tt.value) > 0 {
+			headers.Set("Retry-After", tt.value)
+		}
+		if got := retryAfterHeader(headers, 5*time.Second); got != tt.want {
+			t.Errorf("retryAfterHeader(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+// Request of signal-cli-rest-api, https://bbernhard.github.io/signal-cli-rest-api/#/Messages/post_v2_send
+type signalMessage struct {
+	Message    string   `json:"message"`
+	Number     string   `json:"number"`
+	Recipients []string `json:"recipients"`
+}
+
+type signalResponse struct {
+	Timestamp string `json:"timestamp"`
+	Error     string `json:"error"`
+}
+
+// Channel is configured like SIGNAL_HOME:::url=http://localhost:8080:::number=+420111111111:::+420222222222:::group=group.abcdef==
+func sendSignalWithNotification(channel string, fields []string, notification Notification) {
+	settings, recipients := channelConfig(fields)
+	serverUrl := strings.TrimSuffix(settings["url"], "/")
+	if len(settings["group"]) > 0 {
+		// Group ID as listed by /v1/groups/<number>
+		recipients = append(recipients, settings["group"])
+	}
+	if len(serverUrl) == 0 || len(settings["number"]) == 0 || len(recipients) == 0 {
+		slog.Error("SIGNAL - Channel must have url, number and at least one recipient or group configured.", "channel", channel)
+		return
+	}
+
+	jsonData, err := json.Marshal(signalMessage{Message: notification.Message, Number: settings["number"], Recipients: recipients})
+	if err != nil {
+		slog.Error("Error encoding signal message.", "error", err)
+		return
+	}
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("POST", serverUrl+"/v2/send", []string{"Content-Type: application/json"}, string(jsonData))
+
+	var httpBodyFinal string
+	var response signalResponse
+	if responseBody != nil && json.Unmarshal(responseBody.Bytes(), &response) == nil {
+		if len(response.Error) > 0 {
+			httpBodyFinal = response.Error
+		} else {
+			httpBodyFinal = "success"
+		}
+	} else {
+		httpBodyFinal = responseBody.String()
+	}
+
+	if httpStatusCode > 0 && httpStatusCode < 400 && len(response.Error) == 0 {
+		slog.Debug("SIGNAL", "channel", channel, "response", httpBodyFinal, "timestamp", response.Timestamp)
+	} else {
+		slog.Error("SIGNAL", "channel", channel, "status", httpStatusCode, "response", httpBodyFinal)
+	}
+}
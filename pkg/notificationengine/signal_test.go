@@ -0,0 +1,85 @@
+package notificationengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSendSignal(t *testing.T) {
+	tests := []struct {
+		name           string
+		fields         []string
+		status         int
+		response       string
+		wantRecipients []string
+		wantLog        string
+	}{
+		{
+			name:           "sent",
+			fields:         []string{"number=+420111111111", "+420222222222", "group=group.abc=="},
+			status:         http.StatusCreated,
+			response:       `{"timestamp":"1714566615000"}`,
+			wantRecipients: []string{"+420222222222", "group.abc=="},
+		},
+		{
+			name:           "error in response",
+			fields:         []string{"number=+420111111111", "+420222222222"},
+			status:         http.StatusBadRequest,
+			response:       `{"error":"Failed to send message: Unregistered user"}`,
+			wantRecipients: []string{"+420222222222"},
+			wantLog:        `response="Failed to send message: Unregistered user"`,
+		},
+		{
+			name:           "error with success status",
+			fields:         []string{"number=+420111111111", "+420222222222"},
+			status:         http.StatusOK,
+			response:       `{"error":"Untrusted identity"}`,
+			wantRecipients: []string{"+420222222222"},
+			wantLog:        `response="Untrusted identity"`,
+		},
+		{
+			name:           "response is not json",
+			fields:         []string{"number=+420111111111", "+420222222222"},
+			status:         http.StatusInternalServerError,
+			response:       `Internal Server Error`,
+			wantRecipients: []string{"+420222222222"},
+			wantLog:        `response="Internal Server Error"`,
+		},
+	}
+	for _, tt := range tests {
+		var logged bytes.Buffer
+		defaultLogger := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logged, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+		var gotPath string
+		var got signalMessage
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("%v: request body is not JSON: %v", tt.name, err)
+			}
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.response))
+		}))
+		sendSignalWithNotification("SIGNAL_TEST", append([]string{"url=" + server.URL + "/"}, tt.fields...), Notification{Message: "Fridge is open."})
+		server.Close()
+		slog.SetDefault(defaultLogger)
+
+		want := signalMessage{Message: "Fridge is open.", Number: "+420111111111", Recipients: tt.wantRecipients}
+		if gotPath != "/v2/send" || !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: request to %v with %+v, want /v2/send with %+v", tt.name, gotPath, got, want)
+		}
+		if len(tt.wantLog) == 0 && logged.Len() > 0 {
+			t.Errorf("%v: unexpected log %v", tt.name, logged.String())
+		}
+		if len(tt.wantLog) > 0 && !strings.Contains(logged.String(), tt.wantLog) {
+			t.Errorf("%v: log %q does not contain %q", tt.name, logged.String(), tt.wantLog)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+// Incoming webhook message with attachment, same format is accepted by Slack and Mattermost
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	IconURL     string            `json:"icon_url,omitempty"`
+	Text        string            `json:"text,omitempty"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text"`
+	Fields   []slackField `json:"fields,omitempty"`
+	Footer   string       `json:"footer,omitempty"`
+	Ts       int64        `json:"ts,omitempty"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// SLACK_ and MATTERMOST_ channels are configured like SLACK_OFFICE:::url=https://hooks.slack.com/services/...:::channel=#alerts:::username=tasmota-alerter
+func sendSlackWithNotification(channel string, fields []string, notification Notification) time.Duration {
+	settings, _ := channelConfig(fields)
+	if len(settings["url"]) == 0 {
+		slog.Error("SLACK - Channel must have url configured.", "channel", channel)
+		return 0
+	}
+
+	attachment := slackAttachment{
+		Fallback: notification.title() + ": " + notification.Message,
+		Color:    notification.color(),
+		Title:    notification.title(),
+		Text:     notification.Message,
+		Footer:   "tasmota-alerter",
+		Ts:       notification.Time.Unix(),
+	}
+	for _, field := range notificationFields(notification) {
+		attachment.Fields = append(attachment.Fields, slackField{Title: field[0], Value: field[1], Short: true})
+	}
+	message := slackMessage{
+		Channel:     settings["channel"],
+		Username:    settings["username"],
+		IconEmoji:   settings["icon_emoji"],
+		IconURL:     settings["icon_url"],
+		Attachments: []slackAttachment{attachment},
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("Error encoding slack message.", "error", err)
+		return 0
+	}
+	httpStatusCode, responseBody, responseHeaders := http.CallUrlWithResponseHeaders("POST", settings["url"], []string{"Content-Type: application/json"}, string(jsonData))
+	if httpStatusCode > 0 && httpStatusCode < 400 {
+		slog.Debug("SLACK", "channel", channel, "response", responseBody.String())
+		return 0
+	}
+	slog.Error("SLACK", "channel", channel, "status", httpStatusCode, "response", responseBody.String())
+	if httpStatusCode == 429 {
+		// Incoming webhooks allow about one message per second
+		return retryAfterHeader(responseHeaders, time.Second)
+	}
+	return 0
+}
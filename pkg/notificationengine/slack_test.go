@@ -0,0 +1,76 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSendSlack(t *testing.T) {
+	notificationTime := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	notification := Notification{Device: "plug", RuleID: "power", State: StateFiring, Severity: "critical", Value: "2500", Message: "Power is high.", Time: notificationTime}
+	tests := []struct {
+		name           string
+		fields         []string
+		status         int
+		retryAfter     string
+		want           slackMessage
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:   "attachment",
+			fields: []string{"channel=#alerts", "username=alerter", "icon_emoji=:zap:"},
+			status: http.StatusOK,
+			want: slackMessage{Channel: "#alerts", Username: "alerter", IconEmoji: ":zap:", Attachments: []slackAttachment{{
+				Fallback: "[ plug ] power firing: Power is high.", Color: "#d00000", Title: "[ plug ] power firing", Text: "Power is high.",
+				Fields: []slackField{{Title: "Device", Value: "plug", Short: true}, {Title: "Value", Value: "2500", Short: true}, {Title: "Severity", Value: "critical", Short: true}},
+				Footer: "tasmota-alerter", Ts: notificationTime.Unix(),
+			}}},
+		},
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+			want: slackMessage{Attachments: []slackAttachment{{
+				Fallback: "[ plug ] power firing: Power is high.", Color: "#d00000", Title: "[ plug ] power firing", Text: "Power is high.",
+				Fields: []slackField{{Title: "Device", Value: "plug", Short: true}, {Title: "Value", Value: "2500", Short: true}, {Title: "Severity", Value: "critical", Short: true}},
+				Footer: "tasmota-alerter", Ts: notificationTime.Unix(),
+			}}},
+			wantRetryAfter: time.Second,
+		},
+		{
+			name:       "rate limited with retry after",
+			status:     http.StatusTooManyRequests,
+			retryAfter: "5",
+			want: slackMessage{Attachments: []slackAttachment{{
+				Fallback: "[ plug ] power firing: Power is high.", Color: "#d00000", Title: "[ plug ] power firing", Text: "Power is high.",
+				Fields: []slackField{{Title: "Device", Value: "plug", Short: true}, {Title: "Value", Value: "2500", Short: true}, {Title: "Severity", Value: "critical", Short: true}},
+				Footer: "tasmota-alerter", Ts: notificationTime.Unix(),
+			}}},
+			wantRetryAfter: 5 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		var got slackMessage
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("%v: request body is not JSON: %v", tt.name, err)
+			}
+			if len(tt.retryAfter) > 0 {
+				w.Header().Set("Retry-After", tt.retryAfter)
+			}
+			w.WriteHeader(tt.status)
+		}))
+		retryAfter := sendSlackWithNotification("SLACK_TEST", append([]string{"url=" + server.URL}, tt.fields...), notification)
+		server.Close()
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: message = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if retryAfter != tt.wantRetryAfter {
+			t.Errorf("%v: retry after = %v, want %v", tt.name, retryAfter, tt.wantRetryAfter)
+		}
+	}
+}
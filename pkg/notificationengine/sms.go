@@ -0,0 +1,222 @@
+package notificationengine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"text/template"
+	"unicode/utf16"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+const (
+	smsGatewayAndroid  = "android"
+	smsGatewayTemplate = "template"
+	// Single SMS length in GSM 7-bit alphabet and in UCS-2 (any other characters)
+	smsGsmLength = 160
+	smsUcsLength = 70
+)
+
+// GSM 03.38 basic character set, characters of extension table take two positions
+const (
+	smsGsmBasic     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	smsGsmExtension = "^{}\\[~]|€\f"
+)
+
+// Message for SMS Gateway for Android app (local server mode), https://github.com/capcom6/android-sms-gateway
+type androidSmsMessage struct {
+	Message      string   `json:"message"`
+	PhoneNumbers []string `json:"phoneNumbers"`
+}
+
+// Data of template mode, like url=https://gateway/send?to={{urlquery .Phone}}&text={{urlquery .Text}}
+type smsTemplateData struct {
+	Notification
+	Phone string
+	// Shortened message
+	Text string
+}
+
+// SMS channel parsed when channels are loaded, so errors are reported at startup
+type smsChannel struct {
+	settings map[string]string
+	phones   []string
+	// Templates of request fields in template mode, rendered for every phone number
+	fieldTemplates []*template.Template
+}
+
+var smsChannels = map[string]smsChannel{}
+
+// Channel is configured like SMS_GRANDMA:::gateway=android:::url=http://192.168.1.50:8080:::user=sms:::password=xyz:::+420123456789:::+420987654321
+// Must be called with lock held.
+func loadSmsChannel(channel string, fields []string) {
+	var settingFields, phones []string
+	for _, field := range fields {
+		if strings.Contains(field, "=") {
+			settingFields = append(settingFields, field)
+		} else if phone := strings.TrimSpace(field); len(phone) > 0 {
+			phones = append(phones, phone)
+		}
+	}
+	settings, _ := channelConfig(settingFields)
+	if len(settings["url"]) == 0 || len(phones) == 0 {
+		slog.Error("SMS - Channel must have url and at least one phone number configured.", "channel", channel)
+		return
+	}
+	sms := smsChannel{settings: settings, phones: phones}
+
+	switch settings["gateway"] {
+	case smsGatewayAndroid:
+		// Message is sent to all phone numbers by single request
+	case smsGatewayTemplate:
+		for _, field := range settingFields {
+			key, _, _ := strings.Cut(field, "=")
+			if strings.TrimSpace(key) == "gateway" {
+				continue
+			}
+			fieldTemplate, err := template.New(channel).Funcs(webhookTemplateFuncs).Parse(field)
+			if err != nil {
+				slog.Error("SMS - Can not parse template.", "channel", channel, "field", strings.TrimSpace(key), "error", err)
+				return
+			}
+			sms.fieldTemplates = append(sms.fieldTemplates, fieldTemplate)
+		}
+		// Fields rendered for the first phone number must be valid request configuration
+		if _, err := renderSmsRequest(sms.fieldTemplates, smsTemplateData{Phone: phones[0]}); err != nil {
+			slog.Error("SMS - Invalid channel configuration.", "channel", channel, "error", err)
+			return
+		}
+	default:
+		slog.Error("SMS - Unknown gateway, use android or template.", "channel", channel, "gateway", settings["gateway"])
+		return
+	}
+	smsChannels[channel] = sms
+}
+
+func sendSmsWithNotification(channel string, notification Notification) {
+	lock.Lock()
+	sms, found := smsChannels[channel]
+	lock.Unlock()
+	if !found {
+		slog.Error("SMS - Notification not sent, channel configuration is invalid.", "channel", channel)
+		return
+	}
+	text := shortenSms(notification.Message)
+
+	if len(sms.fieldTemplates) == 0 {
+		sendAndroidSms(channel, sms.settings, sms.phones, text)
+		return
+	}
+	for _, phone := range sms.phones {
+		sendTemplateSms(channel, sms.fieldTemplates, smsTemplateData{Notification: notification, Phone: phone, Text: text})
+	}
+}
+
+func sendAndroidSms(channel string, settings map[string]string, phones []string, text string) {
+	jsonData, err := json.Marshal(androidSmsMessage{Message: text, PhoneNumbers: phones})
+	if err != nil {
+		slog.Error("Error encoding SMS message.", "error", err)
+		return
+	}
+	headers := []string{"Content-Type: application/json"}
+	if len(settings["user"]) > 0 {
+		credentials := base64.StdEncoding.EncodeToString([]byte(settings["user"] + ":" + settings["password"]))
+		headers = append(headers, "Authorization: Basic "+credentials)
+	}
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("POST", strings.TrimSuffix(settings["url"], "/")+"/message", headers, string(jsonData))
+	if httpStatusCode > 0 && httpStatusCode < 400 {
+		slog.Debug("SMS", "channel", channel, "response", responseBody.String())
+		return
+	}
+	slog.Error("SMS", "channel", channel, "status", httpStatusCode, "response", responseBody.String())
+}
+
+// URL, headers and body are templates like in webhook channel, the request is sent for every phone number
+func sendTemplateSms(channel string, fieldTemplates []*template.Template, data smsTemplateData) {
+	config, err := renderSmsRequest(fieldTemplates, data)
+	if err != nil {
+		slog.Error("SMS - Can not create request.", "channel", channel, "phone", data.Phone, "error", err)
+		return
+	}
+	headers := config.Headers
+	if !hasHeader(headers, "Content-Type") {
+		headers = append([]string{"Content-Type: application/json"}, headers...)
+	}
+
+	httpStatusCode, responseBody := http.CallUrlWithHeaders(config.Method, config.URL, headers, config.Body)
+	if httpStatusCode < config.StatusMin || httpStatusCode > config.StatusMax {
+		slog.Error("SMS", "channel", channel, "phone", data.Phone, "status", httpStatusCode, "response", responseBody.String())
+		return
+	}
+	slog.Debug("SMS", "channel", channel, "phone", data.Phone, "status", httpStatusCode)
+}
+
+// Rendered fields are parsed like fields of webhook channel
+func renderSmsRequest(fieldTemplates []*template.Template, data smsTemplateData) (webhookConfig, error) {
+	var requestFields []string
+	for _, fieldTemplate := range fieldTemplates {
+		var rendered bytes.Buffer
+		if err := fieldTemplate.Execute(&rendered, data); err != nil {
+			return webhookConfig{}, err
+		}
+		requestFields = append(requestFields, rendered.String())
+	}
+	return parseWebhookConfig(requestFields)
+}
+
+// Shorten message to single SMS, 160 characters in GSM alphabet or 70 characters when other characters (like emoji) are used
+func shortenSms(message string) string {
+	if length, gsm := smsGsmLengthOf(message); gsm {
+		if length <= smsGsmLength {
+			return message
+		}
+		var shortened strings.Builder
+		used := 0
+		for _, r := range message {
+			size := 1
+			if strings.ContainsRune(smsGsmExtension, r) {
+				size = 2
+			}
+			if used+size > smsGsmLength-3 {
+				break
+			}
+			used += size
+			shortened.WriteRune(r)
+		}
+		return shortened.String() + "..."
+	}
+
+	if len(utf16.Encode([]rune(message))) <= smsUcsLength {
+		return message
+	}
+	var shortened []rune
+	used := 0
+	for _, r := range message {
+		size := len(utf16.Encode([]rune{r}))
+		if used+size > smsUcsLength-1 {
+			break
+		}
+		used += size
+		shortened = append(shortened, r)
+	}
+	return string(shortened) + "…"
+}
+
+// Returns length in GSM 7-bit alphabet and false when message contains characters out of the alphabet
+func smsGsmLengthOf(message string) (int, bool) {
+	length := 0
+	for _, r := range message {
+		switch {
+		case strings.ContainsRune(smsGsmBasic, r):
+			length++
+		case strings.ContainsRune(smsGsmExtension, r):
+			length += 2
+		default:
+			return 0, false
+		}
+	}
+	return length, true
+}
@@ -0,0 +1,165 @@
+package notificationengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"unicode/utf16"
+)
+
+func TestSmsGsmLengthOf(t *testing.T) {
+	tests := []struct {
+		message    string
+		wantLength int
+		wantGsm    bool
+	}{
+		{message: "", wantLength: 0, wantGsm: true},
+		{message: "Power is 95 W.", wantLength: 14, wantGsm: true},
+		{message: "Price 5€", wantLength: 9, wantGsm: true},
+		{message: "{[~]}", wantLength: 10, wantGsm: true},
+		{message: "Teplota 25°C", wantGsm: false},
+		{message: "Pračka běží", wantGsm: false},
+		{message: "Fridge 🧊", wantGsm: false},
+	}
+	for _, tt := range tests {
+		length, gsm := smsGsmLengthOf(tt.message)
+		if gsm != tt.wantGsm || (gsm && length != tt.wantLength) {
+			t.Errorf("smsGsmLengthOf(%q) = %v, %v, want %v, %v", tt.message, length, gsm, tt.wantLength, tt.wantGsm)
+		}
+	}
+}
+
+func TestShortenSms(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{name: "short gsm", message: "Fridge is open.", want: "Fridge is open."},
+		{name: "gsm at limit", message: strings.Repeat("a", 160), want: strings.Repeat("a", 160)},
+		{name: "gsm over limit", message: strings.Repeat("a", 161), want: strings.Repeat("a", 157) + "..."},
+		{name: "gsm extension at limit", message: strings.Repeat("a", 156) + "€€", want: strings.Repeat("a", 156) + "€€"},
+		{name: "gsm extension over limit", message: strings.Repeat("a", 155) + "€€€", want: strings.Repeat("a", 155) + "€..."},
+		{name: "short ucs", message: "Pračka běží.", want: "Pračka běží."},
+		{name: "ucs at limit", message: strings.Repeat("č", 70), want: strings.Repeat("č", 70)},
+		{name: "ucs over limit", message: strings.Repeat("č", 71), want: strings.Repeat("č", 69) + "…"},
+		{name: "surrogate pair is not split", message: strings.Repeat("č", 68) + "🧊🧊", want: strings.Repeat("č", 68) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shortenSms(tt.message)
+			if got != tt.want {
+				t.Errorf("shortenSms() = %q, want %q", got, tt.want)
+			}
+			if length, gsm := smsGsmLengthOf(got); gsm && length > smsGsmLength {
+				t.Errorf("shortened message has %v GSM characters, limit is %v", length, smsGsmLength)
+			}
+			if _, gsm := smsGsmLengthOf(got); !gsm && len(utf16.Encode([]rune(got))) > smsUcsLength {
+				t.Errorf("shortened message has %v UCS-2 characters, limit is %v", len(utf16.Encode([]rune(got))), smsUcsLength)
+			}
+		})
+	}
+}
+
+func TestSendAndroidSms(t *testing.T) {
+	var gotPath, gotAuthorization string
+	var got androidSmsMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuthorization = r.URL.Path, r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	loadTestChannels(t, "SMS_TEST:::gateway=android:::url="+server.URL+"/:::user=sms:::password=xyz:::+420123456789::: +420987654321 ")
+	sendSmsWithNotification("SMS_TEST", Notification{Message: "Fridge is open."})
+	want := androidSmsMessage{Message: "Fridge is open.", PhoneNumbers: []string{"+420123456789", "+420987654321"}}
+	if gotPath != "/message" || gotAuthorization != "Basic c21zOnh5eg==" || !reflect.DeepEqual(got, want) {
+		t.Errorf("request to %v with authorization %q and message %+v, want /message with basic auth and %+v", gotPath, gotAuthorization, got, want)
+	}
+}
+
+func TestSendTemplateSms(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Device")+" "+string(body))
+	}))
+	defer server.Close()
+
+	fields := []string{
+		"gateway=template",
+		"url=" + server.URL + "/send?to={{urlquery .Phone}}&text={{urlquery .Text}}",
+		"method=PUT",
+		"header=X-Device: {{.Device}}",
+		"body={{json .Text}}",
+		"+420123456789",
+		"+420987654321",
+	}
+	loadTestChannels(t, "SMS_TEST:::"+strings.Join(fields, ":::"))
+	sendSmsWithNotification("SMS_TEST", Notification{Device: "plug", Message: "Power is high & rising."})
+	want := []string{
+		`PUT /send?to=%2B420123456789&text=Power+is+high+%26+rising. plug "Power is high \u0026 rising."`,
+		`PUT /send?to=%2B420987654321&text=Power+is+high+%26+rising. plug "Power is high \u0026 rising."`,
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
+
+func TestLoadSmsChannel(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		wantTemplates int
+		wantErr       string
+	}{
+		{name: "android", line: "SMS_TEST:::gateway=android:::url=http://192.168.1.50:8080:::+420123456789"},
+		{name: "template", line: "SMS_TEST:::gateway=template:::url=https://gateway/send?to={{urlquery .Phone}}:::body={{json .Text}}:::+420123456789", wantTemplates: 2},
+		{name: "missing phone", line: "SMS_TEST:::gateway=android:::url=http://192.168.1.50:8080", wantErr: "at least one phone number"},
+		{name: "unknown gateway", line: "SMS_TEST:::gateway=modem:::url=http://192.168.1.50:8080:::+420123456789", wantErr: "Unknown gateway"},
+		{name: "invalid template", line: "SMS_TEST:::gateway=template:::url=https://gateway/send:::body={{.Text:::+420123456789", wantErr: "Can not parse template"},
+		{name: "unknown template field", line: "SMS_TEST:::gateway=template:::url=https://gateway/send:::body={{.Unknown}}:::+420123456789", wantErr: "Invalid channel configuration"},
+		{name: "unknown request field", line: "SMS_TEST:::gateway=template:::url=https://gateway/send:::retries=3:::+420123456789", wantErr: "Invalid channel configuration"},
+	}
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		logger := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelError})))
+		loadTestChannels(t, tt.line)
+		slog.SetDefault(logger)
+
+		sms, loaded := smsChannels["SMS_TEST"]
+		if loaded != (len(tt.wantErr) == 0) || !strings.Contains(logs.String(), tt.wantErr) {
+			t.Errorf("%v: channel loaded = %v, log %q, want error %q", tt.name, loaded, logs.String(), tt.wantErr)
+		}
+		if len(sms.fieldTemplates) != tt.wantTemplates {
+			t.Errorf("%v: %v field templates, want %v", tt.name, len(sms.fieldTemplates), tt.wantTemplates)
+		}
+	}
+}
+
+func TestRenderSmsRequest(t *testing.T) {
+	var fieldTemplates []*template.Template
+	for _, field := range []string{"url=https://gateway/send?to={{urlquery .Phone}}", "body={{.Device}}: {{.Text}}"} {
+		fieldTemplates = append(fieldTemplates, template.Must(template.New("sms").Funcs(webhookTemplateFuncs).Parse(field)))
+	}
+
+	config, err := renderSmsRequest(fieldTemplates, smsTemplateData{Notification: Notification{Device: "plug"}, Phone: "+420123456789", Text: "Hi {{.Device}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rendered text is not a template anymore
+	if config.URL != "https://gateway/send?to=%2B420123456789" || config.Body != "plug: Hi {{.Device}}" {
+		t.Errorf("request url %q and body %q", config.URL, config.Body)
+	}
+}
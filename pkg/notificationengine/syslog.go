@@ -0,0 +1,185 @@
+package notificationengine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	syslogDialTimeout    = 5 * time.Second
+	journaldSocket       = "/run/systemd/journal/socket"
+	defaultSyslogAppName = "tasmota-alerter"
+	// Private enterprise number for structured data ID, 32473 is reserved for documentation and examples (RFC 5612)
+	syslogStructuredDataId = "alert@32473"
+	// RFC 5424 allows at most 6 digits of second fraction
+	syslogTimestampFormat   = "2006-01-02T15:04:05.000000Z07:00"
+	maxSyslogAppNameLength  = 48
+	maxSyslogHostnameLength = 255
+)
+
+var (
+	syslogFacilities = map[string]int{
+		"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+		"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+		"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+	}
+	// Rule severity to syslog severity (crit, warning, informational)
+	syslogSeverities = map[string]int{"info": 6, "warning": 4, "critical": 2}
+)
+
+// Channel is configured like SYSLOG_CENTRAL:::address=udp://logs.example.com:514:::facility=local0:::app=tasmota-alerter
+// Address can be udp://host:port, tcp://host:port, unix:///dev/log or journald.
+func sendSyslogWithNotification(channel string, fields []string, notification Notification) {
+	settings, _ := channelConfig(fields)
+	address := settings["address"]
+	if len(address) == 0 {
+		slog.Error("SYSLOG - Channel must have address configured.", "channel", channel)
+		return
+	}
+	facility, found := syslogFacilities[orDefaultSetting(settings, "facility", "daemon")]
+	if !found {
+		slog.Error("SYSLOG - Unknown facility.", "channel", channel, "facility", settings["facility"])
+		return
+	}
+	appName := orDefaultSetting(settings, "app", defaultSyslogAppName)
+	severity := syslogSeverities[notification.effectiveSeverity()]
+
+	var err error
+	if address == "journald" {
+		err = writeJournal(journaldSocket, journalEntry(appName, facility, severity, notification))
+	} else {
+		err = writeSyslog(address, syslogMessage(appName, facility, severity, notification))
+	}
+	if err != nil {
+		slog.Error("SYSLOG", "channel", channel, "address", address, "error", err)
+		return
+	}
+	slog.Debug("SYSLOG", "channel", channel, "address", address)
+}
+
+func orDefaultSetting(settings map[string]string, key string, defaultValue string) string {
+	if value := settings[key]; len(value) > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// RFC 5424 message: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [STRUCTURED-DATA] MSG
+func syslogMessage(appName string, facility int, severity int, notification Notification) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	msgId := notification.State
+	if len(msgId) == 0 {
+		msgId = "-"
+	}
+
+	var structuredData strings.Builder
+	structuredData.WriteString("[" + syslogStructuredDataId)
+	for _, param := range [][2]string{
+		{"device", notification.Device}, {"rule", notification.RuleID}, {"state", notification.State},
+		{"value", notification.Value}, {"condition", notification.Condition}, {"severity", notification.Severity},
+	} {
+		if len(param[1]) > 0 {
+			fmt.Fprintf(&structuredData, " %v=\"%v\"", param[0], syslogParamValue(param[1]))
+		}
+	}
+	structuredData.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %v %v %v %d %v %v %v", facility*8+severity, notification.Time.Format(syslogTimestampFormat), syslogHeaderField(hostname, maxSyslogHostnameLength),
+		syslogHeaderField(appName, maxSyslogAppNameLength), os.Getpid(), msgId, structuredData.String(), strings.ReplaceAll(notification.Message, "\n", " "))
+}
+
+// Header fields must be printable US-ASCII without spaces, invalid value is replaced by nil value "-"
+func syslogHeaderField(value string, maxLength int) string {
+	if len(value) == 0 || len(value) > maxLength {
+		return "-"
+	}
+	for _, c := range []byte(value) {
+		if c < 33 || c > 126 {
+			return "-"
+		}
+	}
+	return value
+}
+
+// Characters ", \ and ] must be escaped in structured data values
+func syslogParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+func writeSyslog(address string, message string) error {
+	network, host, found := strings.Cut(address, "://")
+	if !found {
+		return fmt.Errorf("address %q must start with udp://, tcp:// or unix://", address)
+	}
+	switch network {
+	case "tcp":
+		// Octet counting framing (RFC 6587), message can contain new lines
+		message = fmt.Sprintf("%d %v", len(message), message)
+	case "udp":
+	case "unix":
+		// Local syslog socket like /dev/log is datagram socket
+		network = "unixgram"
+	default:
+		return fmt.Errorf("unsupported syslog network %q", network)
+	}
+
+	conn, err := net.DialTimeout(network, host, syslogDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetWriteDeadline(time.Now().Add(syslogDialTimeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write([]byte(message))
+	return err
+}
+
+// Journal native protocol, https://systemd.io/JOURNAL_NATIVE_PROTOCOL/
+func journalEntry(appName string, facility int, severity int, notification Notification) []byte {
+	var entry bytes.Buffer
+	for _, field := range [][2]string{
+		{"MESSAGE", notification.Message},
+		{"PRIORITY", fmt.Sprintf("%d", severity)},
+		{"SYSLOG_FACILITY", fmt.Sprintf("%d", facility)},
+		{"SYSLOG_IDENTIFIER", appName},
+		{"TASMOTA_DEVICE", notification.Device},
+		{"TASMOTA_RULE", notification.RuleID},
+		{"TASMOTA_STATE", notification.State},
+		{"TASMOTA_VALUE", notification.Value},
+		{"TASMOTA_CONDITION", notification.Condition},
+		{"TASMOTA_SEVERITY", notification.Severity},
+	} {
+		if len(field[1]) == 0 {
+			continue
+		}
+		if !strings.Contains(field[1], "\n") {
+			fmt.Fprintf(&entry, "%v=%v\n", field[0], field[1])
+			continue
+		}
+		// Value with new lines is written with its size as 64 bit little endian number
+		entry.WriteString(field[0] + "\n")
+		_ = binary.Write(&entry, binary.LittleEndian, uint64(len(field[1])))
+		entry.WriteString(field[1] + "\n")
+	}
+	return entry.Bytes()
+}
+
+func writeJournal(socket string, entry []byte) error {
+	conn, err := net.DialTimeout("unixgram", socket, syslogDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(entry)
+	return err
+}
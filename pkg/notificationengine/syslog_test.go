@@ -0,0 +1,121 @@
+package notificationengine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogParamValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "plug-fridge", want: "plug-fridge"},
+		{value: `power "high"`, want: `power \"high\"`},
+		{value: `C:\temp`, want: `C:\\temp`},
+		{value: "[0]", want: `[0\]`},
+		{value: `\"]`, want: `\\\"\]`},
+	}
+	for _, tt := range tests {
+		if got := syslogParamValue(tt.value); got != tt.want {
+			t.Errorf("syslogParamValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSyslogHeaderField(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "tasmota-alerter", want: "tasmota-alerter"},
+		{value: "", want: "-"},
+		{value: "tasmota alerter", want: "-"},
+		{value: "alerter\n", want: "-"},
+		{value: "alertér", want: "-"},
+		{value: strings.Repeat("a", 48), want: strings.Repeat("a", 48)},
+		{value: strings.Repeat("a", 49), want: "-"},
+	}
+	for _, tt := range tests {
+		if got := syslogHeaderField(tt.value, maxSyslogAppNameLength); got != tt.want {
+			t.Errorf("syslogHeaderField(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSyslogMessage(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip("hostname is not available")
+	}
+	notification := Notification{
+		Device:  "plug-fridge",
+		RuleID:  "fridge-power",
+		State:   StateFiring,
+		Value:   "95",
+		Message: "Fridge power is high.\nCheck the door.",
+		Time:    time.Date(2024, 5, 1, 12, 30, 15, 123456789, time.UTC),
+	}
+	want := fmt.Sprintf(`<132>1 2024-05-01T12:30:15.123456Z %v tasmota-alerter %d firing [alert@32473 device="plug-fridge" rule="fridge-power" state="firing" value="95"] Fridge power is high. Check the door.`,
+		syslogHeaderField(hostname, maxSyslogHostnameLength), os.Getpid())
+	if got := syslogMessage("tasmota-alerter", 16, 4, notification); got != want {
+		t.Errorf("syslogMessage() =\n%v\nwant\n%v", got, want)
+	}
+	if got := syslogMessage("tasmota alerter", 16, 4, notification); !strings.Contains(got, " - "+fmt.Sprint(os.Getpid())) {
+		t.Errorf("syslogMessage() with invalid app name = %v, want nil app name", got)
+	}
+}
+
+func TestJournalEntry(t *testing.T) {
+	notification := Notification{Device: "plug-fridge", State: StateResolved, Message: "Fridge power is normal."}
+	want := "MESSAGE=Fridge power is normal.\nPRIORITY=6\nSYSLOG_FACILITY=3\nSYSLOG_IDENTIFIER=tasmota-alerter\nTASMOTA_DEVICE=plug-fridge\nTASMOTA_STATE=resolved\n"
+	if got := string(journalEntry("tasmota-alerter", 3, 6, notification)); got != want {
+		t.Errorf("journalEntry() = %q, want %q", got, want)
+	}
+
+	// Value with new lines is written with its size
+	notification = Notification{Message: "line 1\nline 2"}
+	var multiline bytes.Buffer
+	multiline.WriteString("MESSAGE\n")
+	_ = binary.Write(&multiline, binary.LittleEndian, uint64(len("line 1\nline 2")))
+	multiline.WriteString("line 1\nline 2\n")
+	multiline.WriteString("PRIORITY=2\nSYSLOG_FACILITY=16\nSYSLOG_IDENTIFIER=app\n")
+	if got := journalEntry("app", 16, 2, notification); !bytes.Equal(got, multiline.Bytes()) {
+		t.Errorf("journalEntry() = %q, want %q", got, multiline.Bytes())
+	}
+}
+
+func TestWriteSyslog(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("UDP is not available")
+	}
+	defer listener.Close()
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "udp://" + listener.LocalAddr().String()},
+		{address: listener.LocalAddr().String(), wantErr: true},
+		{address: "http://" + listener.LocalAddr().String(), wantErr: true},
+	}
+	for _, tt := range tests {
+		err := writeSyslog(tt.address, "<30>1 - - tasmota-alerter - - - test")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("writeSyslog(%q) error = %v, want error %v", tt.address, err, tt.wantErr)
+		}
+	}
+
+	buffer := make([]byte, 1024)
+	_ = listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buffer)
+	if err != nil || string(buffer[:n]) != "<30>1 - - tasmota-alerter - - - test" {
+		t.Errorf("received %q, %v", buffer[:n], err)
+	}
+}
@@ -0,0 +1,101 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+// Message with Adaptive Card for Teams workflow "Post to a channel when a webhook request is received"
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string `json:"contentType"`
+	Content     any    `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string `json:"$schema"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	Body    []any  `json:"body"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Container style and text color of Adaptive Card by state of alert
+func teamsStateStyle(notification Notification) (string, string) {
+	switch notification.State {
+	case StateResolved, StateStable:
+		return "good", "Good"
+	case StateFiring, StateSafety:
+		if notification.effectiveSeverity() == "critical" {
+			return "attention", "Attention"
+		}
+		return "warning", "Warning"
+	case StateReminder, StateFlapping:
+		return "warning", "Warning"
+	}
+	return "emphasis", "Default"
+}
+
+// Channel is configured like TEAMS_FACILITY:::url=https://prod-00.westeurope.logic.azure.com/workflows/...
+func sendTeamsWithNotification(channel string, fields []string, notification Notification) time.Duration {
+	settings, _ := channelConfig(fields)
+	if len(settings["url"]) == 0 {
+		slog.Error("TEAMS - Channel must have url configured.", "channel", channel)
+		return 0
+	}
+
+	style, color := teamsStateStyle(notification)
+	facts := []teamsFact{}
+	for _, field := range notificationFields(notification) {
+		facts = append(facts, teamsFact{Title: field[0], Value: field[1]})
+	}
+	facts = append(facts, teamsFact{Title: "Time", Value: notification.Time.Format(time.DateTime)})
+	card := teamsCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []any{
+			map[string]any{
+				"type":  "Container",
+				"style": style,
+				"bleed": true,
+				"items": []any{
+					map[string]any{"type": "TextBlock", "text": notification.title(), "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+				},
+			},
+			map[string]any{"type": "TextBlock", "text": notification.Message, "wrap": true},
+			map[string]any{"type": "FactSet", "facts": facts},
+		},
+	}
+	message := teamsMessage{
+		Type:        "message",
+		Attachments: []teamsAttachment{{ContentType: "application/vnd.microsoft.card.adaptive", Content: card}},
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		slog.Error("Error encoding teams message.", "error", err)
+		return 0
+	}
+	httpStatusCode, responseBody, responseHeaders := http.CallUrlWithResponseHeaders("POST", settings["url"], []string{"Content-Type: application/json"}, string(jsonData))
+	if httpStatusCode > 0 && httpStatusCode < 400 {
+		slog.Debug("TEAMS", "channel", channel, "status", httpStatusCode)
+		return 0
+	}
+	slog.Error("TEAMS", "channel", channel, "status", httpStatusCode, "response", responseBody.String())
+	if httpStatusCode == 429 {
+		return retryAfterHeader(responseHeaders, 30*time.Second)
+	}
+	return 0
+}
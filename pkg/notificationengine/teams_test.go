@@ -0,0 +1,86 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTeamsStateStyle(t *testing.T) {
+	tests := []struct {
+		notification Notification
+		wantStyle    string
+		wantColor    string
+	}{
+		{notification: Notification{State: StateFiring, Severity: "critical"}, wantStyle: "attention", wantColor: "Attention"},
+		{notification: Notification{State: StateSafety, Severity: "critical"}, wantStyle: "attention", wantColor: "Attention"},
+		{notification: Notification{State: StateFiring, Severity: "warning"}, wantStyle: "warning", wantColor: "Warning"},
+		{notification: Notification{State: StateReminder, Severity: "critical"}, wantStyle: "warning", wantColor: "Warning"},
+		{notification: Notification{State: StateResolved, Severity: "critical"}, wantStyle: "good", wantColor: "Good"},
+		{notification: Notification{State: StateReport}, wantStyle: "emphasis", wantColor: "Default"},
+	}
+	for _, tt := range tests {
+		style, color := teamsStateStyle(tt.notification)
+		if style != tt.wantStyle || color != tt.wantColor {
+			t.Errorf("teamsStateStyle(%+v) = %v, %v, want %v, %v", tt.notification, style, color, tt.wantStyle, tt.wantColor)
+		}
+	}
+}
+
+func TestSendTeams(t *testing.T) {
+	notification := Notification{Device: "plug", RuleID: "power", State: StateFiring, Severity: "critical", Value: "2500", Message: "Power is high.", Time: time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		name           string
+		status         int
+		retryAfter     string
+		wantRetryAfter time.Duration
+	}{
+		{name: "sent", status: http.StatusAccepted},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantRetryAfter: 30 * time.Second},
+		{name: "rate limited with retry after", status: http.StatusTooManyRequests, retryAfter: "7", wantRetryAfter: 7 * time.Second},
+		{name: "bad request", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		var got map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("%v: request body is not JSON: %v", tt.name, err)
+			}
+			if len(tt.retryAfter) > 0 {
+				w.Header().Set("Retry-After", tt.retryAfter)
+			}
+			w.WriteHeader(tt.status)
+		}))
+		retryAfter := sendTeamsWithNotification("TEAMS_TEST", []string{"url=" + server.URL}, notification)
+		server.Close()
+
+		if retryAfter != tt.wantRetryAfter {
+			t.Errorf("%v: retry after = %v, want %v", tt.name, retryAfter, tt.wantRetryAfter)
+		}
+		attachment := got["attachments"].([]any)[0].(map[string]any)
+		if got["type"] != "message" || attachment["contentType"] != "application/vnd.microsoft.card.adaptive" {
+			t.Fatalf("%v: message = %v, want adaptive card attachment", tt.name, got)
+		}
+		body := attachment["content"].(map[string]any)["body"].([]any)
+		header := body[0].(map[string]any)
+		title := header["items"].([]any)[0].(map[string]any)
+		if header["style"] != "attention" || title["text"] != "[ plug ] power firing" || title["color"] != "Attention" {
+			t.Errorf("%v: header = %v", tt.name, header)
+		}
+		if text := body[1].(map[string]any)["text"]; text != "Power is high." {
+			t.Errorf("%v: text = %v", tt.name, text)
+		}
+		wantFacts := []any{
+			map[string]any{"title": "Device", "value": "plug"},
+			map[string]any{"title": "Value", "value": "2500"},
+			map[string]any{"title": "Severity", "value": "critical"},
+			map[string]any{"title": "Time", "value": "2024-05-06 08:00:00"},
+		}
+		if facts := body[2].(map[string]any)["facts"]; !reflect.DeepEqual(facts, wantFacts) {
+			t.Errorf("%v: facts = %v, want %v", tt.name, facts, wantFacts)
+		}
+	}
+}
@@ -1,29 +1,46 @@
 package notificationengine
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
-	"encoding/json"
+	"time"
 
 	"github.com/jorycz/tasmota-alerter/pkg/http"
 )
 
 // JSON struct for response
 type JsonResponse struct {
-	OK bool `json:"ok"`
+	OK     bool `json:"ok"`
+	Result struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"result"`
+	Parameters struct {
+		RetryAfter int64 `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+type telegramMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
 }
 
-func sendTelegramMessage(botToken, chatId, message string) {
+// Returns ID of sent message or 0 if message was not sent and time to wait before retry if telegram asks for it
+func sendTelegramMessage(botToken, chatId, message string) (int64, time.Duration) {
 	slog.Debug("TELEGRAM", "botToken", botToken, "chatId", chatId, "message", message)
 
-	jsonData := fmt.Sprintf(`{"chat_id": "%v", "text": "%v"}`, chatId, message)
+	jsonData, err := json.Marshal(telegramMessage{ChatID: chatId, Text: message})
+	if err != nil {
+		slog.Error("Error encoding telegram message.", "error", err)
+		return 0, 0
+	}
 	dstUrl := fmt.Sprintf(`https://api.telegram.org/%v/sendMessage`, botToken)
 
-	http.SetHeader("Content-Type: application/json")
-
-	httpStatusCode, responseBody := http.CallUrlWithOptionalTextData(dstUrl, jsonData)
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("POST", dstUrl, []string{"Content-Type: application/json"}, string(jsonData))
 
 	var httpBodyFinal string
+	var messageId int64
+	var retryAfter time.Duration
 
 	if httpStatusCode > 0 && httpStatusCode < 400 {
 		// Read the response in JSON format
@@ -31,22 +48,31 @@ func sendTelegramMessage(botToken, chatId, message string) {
 		decoder := json.NewDecoder(responseBody)
 		err := decoder.Decode(&tokenResponse)
 		if err != nil {
-			slog.Error("Error decoding JSON: %v", err)
+			slog.Error("Error decoding JSON.", "error", err)
 			httpBodyFinal = responseBody.String()
 		} else {
 			if tokenResponse.OK {
 				httpBodyFinal = "success"
+				messageId = tokenResponse.Result.MessageID
 			} else {
 				httpBodyFinal = responseBody.String()
 			}
 		}
 	} else {
 		httpBodyFinal = responseBody.String()
+		// Too Many Requests - telegram tells how long to wait
+		if httpStatusCode == 429 {
+			var tokenResponse JsonResponse
+			if err := json.Unmarshal([]byte(httpBodyFinal), &tokenResponse); err == nil {
+				retryAfter = time.Duration(tokenResponse.Parameters.RetryAfter) * time.Second
+			}
+		}
 	}
 
 	if httpStatusCode > 0 && httpStatusCode < 400 {
 		slog.Debug("TELEGRAM", "response", httpBodyFinal)
 	} else {
-		slog.Error("TELEGRAM", "response", httpBodyFinal)
+		slog.Error("TELEGRAM", "response", httpBodyFinal, "retry_after", retryAfter)
 	}
+	return messageId, retryAfter
 }
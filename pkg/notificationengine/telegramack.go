@@ -0,0 +1,175 @@
+package notificationengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+// AckHandler acknowledges alert of rule on device by user
+type AckHandler func(device string, ruleID string, user string) error
+
+type telegramUpdatesResponse struct {
+	OK     bool `json:"ok"`
+	Result []struct {
+		UpdateID int64 `json:"update_id"`
+		Message  struct {
+			MessageID int64  `json:"message_id"`
+			Text      string `json:"text"`
+			Chat      struct {
+				ID int64 `json:"id"`
+			} `json:"chat"`
+			From struct {
+				Username  string `json:"username"`
+				FirstName string `json:"first_name"`
+			} `json:"from"`
+			ReplyToMessage *struct {
+				MessageID int64 `json:"message_id"`
+			} `json:"reply_to_message"`
+		} `json:"message"`
+	} `json:"result"`
+}
+
+// Alert messages older than this can not be acknowledged by reply anymore
+const telegramAlertMessageMaxAge = 7 * 24 * time.Hour
+
+var (
+	// Sent alert messages by "chatId/messageId" - reply to such message acknowledges the alert
+	telegramAlertMessages = map[string]Notification{}
+	telegramLock          sync.Mutex
+)
+
+func rememberTelegramAlertMessage(chatId string, messageId int64, notification Notification) {
+	telegramLock.Lock()
+	defer telegramLock.Unlock()
+	for key, sent := range telegramAlertMessages {
+		if time.Since(sent.Time) > telegramAlertMessageMaxAge {
+			delete(telegramAlertMessages, key)
+		}
+	}
+	telegramAlertMessages[telegramMessageKey(chatId, messageId)] = notification
+}
+
+// Alert was resolved or acknowledged - its messages are not needed anymore
+func forgetTelegramAlertMessages(device string, ruleID string) {
+	telegramLock.Lock()
+	defer telegramLock.Unlock()
+	for key, sent := range telegramAlertMessages {
+		if sent.Device == device && sent.RuleID == ruleID {
+			delete(telegramAlertMessages, key)
+		}
+	}
+}
+
+func telegramAlertMessage(chatId string, messageId int64) (Notification, bool) {
+	telegramLock.Lock()
+	defer telegramLock.Unlock()
+	notification, found := telegramAlertMessages[telegramMessageKey(chatId, messageId)]
+	return notification, found
+}
+
+func telegramMessageKey(chatId string, messageId int64) string {
+	return fmt.Sprintf("%v/%v", chatId, messageId)
+}
+
+// Periodically read replies sent to telegram bots. Reply "ack" (or "/ack") to alert message acknowledges the alert.
+func StartTelegramAckPolling(pollSeconds int, handler AckHandler) {
+	if pollSeconds <= 0 {
+		return
+	}
+	slog.Info("Polling telegram for alert acknowledgements.", "seconds", pollSeconds)
+	go func() {
+		// Last processed update per bot token
+		offsets := map[string]int64{}
+		ticker := time.NewTicker(time.Duration(pollSeconds) * time.Second)
+		for range ticker.C {
+			for _, botToken := range telegramBotTokens() {
+				offsets[botToken] = pollTelegramUpdates(botToken, offsets[botToken], handler)
+			}
+		}
+	}()
+}
+
+func telegramBotTokens() []string {
+	lock.Lock()
+	defer lock.Unlock()
+	var botTokens []string
+	for channel, botTokenAndChatId := range notificationChannels {
+		if strings.HasPrefix(channel, "TELEGRAM") && len(botTokenAndChatId) > 1 && !slices.Contains(botTokens, botTokenAndChatId[0]) {
+			botTokens = append(botTokens, botTokenAndChatId[0])
+		}
+	}
+	return botTokens
+}
+
+// Returns offset for next poll
+func pollTelegramUpdates(botToken string, offset int64, handler AckHandler) int64 {
+	dstUrl := fmt.Sprintf(`https://api.telegram.org/%v/getUpdates?offset=%v`, botToken, offset)
+	httpStatusCode, responseBody := http.CallUrlWithHeaders("GET", dstUrl, nil, "")
+	if httpStatusCode <= 0 || httpStatusCode >= 400 {
+		slog.Error("TELEGRAM", "response", responseBody.String())
+		return offset
+	}
+
+	var updates telegramUpdatesResponse
+	if err := json.NewDecoder(responseBody).Decode(&updates); err != nil {
+		slog.Error("Error decoding telegram updates.", "error", err)
+		return offset
+	}
+
+	for _, update := range updates.Result {
+		offset = update.UpdateID + 1
+		message := update.Message
+		if message.ReplyToMessage == nil {
+			continue
+		}
+		text := strings.ToLower(strings.TrimSpace(message.Text))
+		if text != "ack" && !strings.HasPrefix(text, "/ack") {
+			continue
+		}
+		chatId := fmt.Sprintf("%v", message.Chat.ID)
+		notification, found := telegramAlertMessage(chatId, message.ReplyToMessage.MessageID)
+		if !found {
+			slog.Debug("TELEGRAM - Reply to unknown alert message ignored.", "chatId", chatId, "messageId", message.ReplyToMessage.MessageID)
+			continue
+		}
+
+		user := "telegram:" + message.From.FirstName
+		if len(message.From.Username) > 0 {
+			user = "telegram:@" + message.From.Username
+		}
+		reply := fmt.Sprintf("Alert [ %v ] of [ %v ] acknowledged by %v.", notification.RuleID, notification.Device, user)
+		if err := handler(notification.Device, notification.RuleID, user); err != nil {
+			reply = fmt.Sprintf("Alert [ %v ] of [ %v ] could not be acknowledged: %v", notification.RuleID, notification.Device, err)
+		} else {
+			forgetTelegramAlertMessages(notification.Device, notification.RuleID)
+		}
+		sendTelegramReply(botToken, chatId, reply)
+	}
+	return offset
+}
+
+// Reply goes through rate limit of telegram channel with the same bot and chat
+func sendTelegramReply(botToken, chatId, reply string) {
+	lock.Lock()
+	var replyChannel string
+	for channel, botTokenAndChatId := range notificationChannels {
+		if strings.HasPrefix(channel, "TELEGRAM") && len(botTokenAndChatId) > 1 && botTokenAndChatId[0] == botToken && botTokenAndChatId[1] == chatId {
+			replyChannel = channel
+			break
+		}
+	}
+	lock.Unlock()
+
+	if len(replyChannel) > 0 {
+		submitToChannel(replyChannel, Notification{Message: reply, Time: time.Now()})
+	} else {
+		sendTelegramMessage(botToken, chatId, reply)
+	}
+}
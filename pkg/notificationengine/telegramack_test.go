@@ -0,0 +1,79 @@
+package notificationengine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTelegramAlertMessage(t *testing.T) {
+	telegramAlertMessages = map[string]Notification{}
+	rememberTelegramAlertMessage("-1001", 42, Notification{Device: "plug-freezer", RuleID: "freezer-stopped"})
+
+	tests := []struct {
+		chatId    string
+		messageId int64
+		wantFound bool
+	}{
+		{chatId: "-1001", messageId: 42, wantFound: true},
+		{chatId: "-1001", messageId: 43},
+		{chatId: "-1002", messageId: 42},
+	}
+	for _, tt := range tests {
+		notification, found := telegramAlertMessage(tt.chatId, tt.messageId)
+		if found != tt.wantFound {
+			t.Errorf("telegramAlertMessage(%v, %v) found = %v, want %v", tt.chatId, tt.messageId, found, tt.wantFound)
+		}
+		if found && (notification.Device != "plug-freezer" || notification.RuleID != "freezer-stopped") {
+			t.Errorf("telegramAlertMessage(%v, %v) = %+v", tt.chatId, tt.messageId, notification)
+		}
+	}
+}
+
+func TestForgetTelegramAlertMessages(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		device   string
+		ruleID   string
+		wantKept []int64
+	}{
+		{name: "resolved alert", device: "plug-freezer", ruleID: "freezer-stopped", wantKept: []int64{3}},
+		{name: "other device", device: "plug-fridge", ruleID: "freezer-stopped", wantKept: []int64{1, 2, 3}},
+		{name: "other rule", device: "plug-freezer", ruleID: "offline", wantKept: []int64{1, 2}},
+	}
+	for _, tt := range tests {
+		telegramAlertMessages = map[string]Notification{}
+		rememberTelegramAlertMessage("-1001", 1, Notification{Device: "plug-freezer", RuleID: "freezer-stopped", Time: now})
+		rememberTelegramAlertMessage("-1002", 2, Notification{Device: "plug-freezer", RuleID: "freezer-stopped", Time: now})
+		rememberTelegramAlertMessage("-1001", 3, Notification{Device: "plug-freezer", RuleID: "offline", Time: now})
+		forgetTelegramAlertMessages(tt.device, tt.ruleID)
+		for _, messageId := range []int64{1, 2, 3} {
+			chatId := "-1001"
+			if messageId == 2 {
+				chatId = "-1002"
+			}
+			_, found := telegramAlertMessage(chatId, messageId)
+			wantFound := false
+			for _, kept := range tt.wantKept {
+				wantFound = wantFound || kept == messageId
+			}
+			if found != wantFound {
+				t.Errorf("%v: message %v found = %v, want %v", tt.name, messageId, found, wantFound)
+			}
+		}
+	}
+	telegramAlertMessages = map[string]Notification{}
+}
+
+func TestRememberTelegramAlertMessageRemovesOld(t *testing.T) {
+	telegramAlertMessages = map[string]Notification{}
+	t.Cleanup(func() { telegramAlertMessages = map[string]Notification{} })
+	rememberTelegramAlertMessage("-1001", 1, Notification{Device: "plug", RuleID: "power", Time: time.Now().Add(-8 * 24 * time.Hour)})
+	rememberTelegramAlertMessage("-1001", 2, Notification{Device: "plug", RuleID: "power", Time: time.Now()})
+	if _, found := telegramAlertMessage("-1001", 1); found {
+		t.Error("message older than max age was not removed")
+	}
+	if _, found := telegramAlertMessage("-1001", 2); !found {
+		t.Error("new message was not remembered")
+	}
+}
@@ -0,0 +1,156 @@
+package notificationengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jorycz/tasmota-alerter/pkg/http"
+)
+
+const (
+	defaultWebhookMethod    = "POST"
+	defaultWebhookStatusMin = 200
+	defaultWebhookStatusMax = 299
+)
+
+// Webhook channel is configured like WEBHOOK_HA:::url=https://...:::method=POST:::header=Authorization: Bearer xyz:::body={"text":{{json .Message}}}
+type webhookConfig struct {
+	URL     string
+	Method  string
+	Headers []string
+	// Go template of request body, notification is passed as data. Without template notification is sent as JSON.
+	Body      string
+	StatusMin int
+	StatusMax int
+	// Compiled body, set when configuration is loaded
+	bodyTemplate *template.Template
+}
+
+// Configuration of webhook channels is parsed when channels are loaded, so errors are reported at startup
+var webhookConfigs = map[string]webhookConfig{}
+
+var webhookTemplateFuncs = template.FuncMap{
+	// Encode value as JSON, like {{json .Message}} produces quoted and escaped string
+	"json": func(value any) (string, error) {
+		jsonData, err := json.Marshal(value)
+		return string(jsonData), err
+	},
+}
+
+func parseWebhookConfig(fields []string) (webhookConfig, error) {
+	config := webhookConfig{Method: defaultWebhookMethod, StatusMin: defaultWebhookStatusMin, StatusMax: defaultWebhookStatusMax}
+	for _, field := range fields {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return config, fmt.Errorf("field %q is not in key=value format", field)
+		}
+		key = strings.TrimSpace(key)
+		switch key {
+		case "url":
+			config.URL = strings.TrimSpace(value)
+		case "method":
+			config.Method = strings.ToUpper(strings.TrimSpace(value))
+		case "header":
+			config.Headers = append(config.Headers, strings.TrimSpace(value))
+		case "body":
+			config.Body = value
+		case "body_file":
+			body, err := os.ReadFile(strings.TrimSpace(value))
+			if err != nil {
+				return config, fmt.Errorf("can't read body template: %w", err)
+			}
+			config.Body = string(body)
+		case "status":
+			// Accepted range of response status codes, like 200-299
+			minStr, maxStr, found := strings.Cut(strings.TrimSpace(value), "-")
+			if !found {
+				maxStr = minStr
+			}
+			statusMin, errMin := strconv.Atoi(minStr)
+			statusMax, errMax := strconv.Atoi(maxStr)
+			if errMin != nil || errMax != nil || statusMin > statusMax {
+				return config, fmt.Errorf("status range %q must be in format min-max, like 200-299", value)
+			}
+			config.StatusMin = statusMin
+			config.StatusMax = statusMax
+		default:
+			return config, fmt.Errorf("unknown webhook field %q", key)
+		}
+	}
+	if len(config.URL) == 0 {
+		return config, fmt.Errorf("webhook url is not set")
+	}
+	return config, nil
+}
+
+// Parse configuration and compile body template of webhook channel. Must be called with lock held.
+func loadWebhookChannel(channel string, fields []string) {
+	config, err := parseWebhookConfig(fields)
+	if err == nil && len(config.Body) > 0 {
+		config.bodyTemplate, err = template.New(channel).Funcs(webhookTemplateFuncs).Parse(config.Body)
+	}
+	if err != nil {
+		slog.Error("WEBHOOK - Invalid channel configuration.", "channel", channel, "error", err)
+		return
+	}
+	webhookConfigs[channel] = config
+}
+
+func sendWebhookWithNotification(channel string, notification Notification) {
+	lock.Lock()
+	config, found := webhookConfigs[channel]
+	lock.Unlock()
+	if !found {
+		slog.Error("WEBHOOK - Notification not sent, channel configuration is invalid.", "channel", channel)
+		return
+	}
+
+	body, err := webhookBody(config, notification)
+	if err != nil {
+		slog.Error("WEBHOOK - Can not create request body.", "channel", channel, "error", err)
+		return
+	}
+	headers := config.Headers
+	if !hasHeader(headers, "Content-Type") {
+		headers = append([]string{"Content-Type: application/json"}, headers...)
+	}
+
+	httpStatusCode, responseBody := http.CallUrlWithHeaders(config.Method, config.URL, headers, body)
+	if httpStatusCode < config.StatusMin || httpStatusCode > config.StatusMax {
+		response := ""
+		if responseBody != nil {
+			response = responseBody.String()
+		}
+		slog.Error("WEBHOOK - Request failed.", "channel", channel, "url", config.URL, "status", httpStatusCode, "response", response)
+		return
+	}
+	slog.Info("WEBHOOK - Sent.", "channel", channel, "status", httpStatusCode)
+}
+
+func webhookBody(config webhookConfig, notification Notification) (string, error) {
+	if config.bodyTemplate == nil {
+		jsonData, err := json.Marshal(notification)
+		return string(jsonData), err
+	}
+	var body bytes.Buffer
+	if err := config.bodyTemplate.Execute(&body, notification); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+func hasHeader(headers []string, name string) bool {
+	for _, header := range headers {
+		headerName, _, _ := strings.Cut(header, ":")
+		if strings.EqualFold(strings.TrimSpace(headerName), name) {
+			return true
+		}
+	}
+	return false
+}
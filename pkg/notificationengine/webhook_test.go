@@ -0,0 +1,167 @@
+package notificationengine
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWebhookConfig(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "body.tmpl")
+	if err := os.WriteFile(bodyFile, []byte(`{"text":{{json .Message}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		fields  []string
+		want    webhookConfig
+		wantErr bool
+	}{
+		{
+			name:   "defaults",
+			fields: []string{"url=https://example.com/hook"},
+			want:   webhookConfig{URL: "https://example.com/hook", Method: "POST", StatusMin: 200, StatusMax: 299},
+		},
+		{
+			name:   "all fields",
+			fields: []string{"url=https://example.com/hook", "method=put", "header=Authorization: Bearer xyz", "header=X-Source: alerter", "body={{.Message}}", "status=200-201"},
+			want: webhookConfig{URL: "https://example.com/hook", Method: "PUT", Headers: []string{"Authorization: Bearer xyz", "X-Source: alerter"},
+				Body: "{{.Message}}", StatusMin: 200, StatusMax: 201},
+		},
+		{
+			name:   "body file and single status",
+			fields: []string{"url=https://example.com/hook", "body_file=" + bodyFile, "status=204"},
+			want:   webhookConfig{URL: "https://example.com/hook", Method: "POST", Body: `{"text":{{json .Message}}}`, StatusMin: 204, StatusMax: 204},
+		},
+		{name: "missing url", fields: []string{"method=POST"}, wantErr: true},
+		{name: "missing body file", fields: []string{"url=https://example.com/hook", "body_file=/nonexistent/body.tmpl"}, wantErr: true},
+		{name: "invalid status", fields: []string{"url=https://example.com/hook", "status=299-200"}, wantErr: true},
+		{name: "unknown field", fields: []string{"url=https://example.com/hook", "retries=3"}, wantErr: true},
+		{name: "not key value", fields: []string{"https://example.com/hook"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseWebhookConfig(tt.fields)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: parseWebhookConfig() error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: parseWebhookConfig() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookBody(t *testing.T) {
+	notification := Notification{Device: "plug", RuleID: "power", State: StateFiring, Severity: "critical", Message: `Power is "high".`, Time: time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "escaped message", body: `{"text":{{json .Message}}}`, want: `{"text":"Power is \"high\"."}`},
+		{name: "fields", body: `{{.Device}}/{{.RuleID}} {{.State}} {{.Severity}}`, want: `plug/power firing critical`},
+		{name: "time", body: `{{.Time.Format "2006-01-02"}}`, want: `2024-05-06`},
+		{
+			name: "notification as json",
+			want: `{"device":"plug","rule":"power","state":"firing","value":"","condition":"","severity":"critical","message":"Power is \"high\".","time":"2024-05-06T08:00:00Z","fired_at":"0001-01-01T00:00:00Z","resolved_at":"0001-01-01T00:00:00Z"}`,
+		},
+		{name: "unknown field", body: `{{.Colour}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		fields := []string{"url=https://example.com/hook"}
+		if len(tt.body) > 0 {
+			fields = append(fields, "body="+tt.body)
+		}
+		loadTestChannels(t, "WEBHOOK_TEST:::"+strings.Join(fields, ":::"))
+		got, err := webhookBody(webhookConfigs["WEBHOOK_TEST"], notification)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: webhookBody() error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v: webhookBody() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var gotMethod, gotContentType, gotAuthorization, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotMethod, gotContentType, gotAuthorization, gotBody = r.Method, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	loadTestChannels(t, "WEBHOOK_TEST:::url="+server.URL+":::method=PUT:::header=Authorization: Bearer xyz:::body={{.Device}}")
+	sendWebhookWithNotification("WEBHOOK_TEST", Notification{Device: "plug"})
+	if gotMethod != "PUT" || gotContentType != "application/json" || gotAuthorization != "Bearer xyz" || gotBody != "plug" {
+		t.Errorf("request = %v %q %q %q, want PUT with JSON content type, authorization and rendered body", gotMethod, gotContentType, gotAuthorization, gotBody)
+	}
+
+	// Channel with invalid configuration is reported when loaded and does not send anything
+	gotMethod = ""
+	loadTestChannels(t, "WEBHOOK_TEST:::url="+server.URL+":::body={{.Device")
+	sendWebhookWithNotification("WEBHOOK_TEST", Notification{Device: "plug"})
+	if len(gotMethod) > 0 {
+		t.Errorf("webhook with invalid configuration sent %v request", gotMethod)
+	}
+}
+
+func TestLoadWebhookChannel(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		wantLoaded bool
+	}{
+		{name: "without body", line: "WEBHOOK_TEST:::url=https://example.com/hook", wantLoaded: true},
+		{name: "body template", line: "WEBHOOK_TEST:::url=https://example.com/hook:::body={{json .Message}}", wantLoaded: true},
+		{name: "invalid body template", line: "WEBHOOK_TEST:::url=https://example.com/hook:::body={{.Message"},
+		{name: "unknown template function", line: "WEBHOOK_TEST:::url=https://example.com/hook:::body={{xml .Message}}"},
+		{name: "missing url", line: "WEBHOOK_TEST:::method=POST"},
+	}
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		logger := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelError})))
+		loadTestChannels(t, tt.line)
+		slog.SetDefault(logger)
+
+		config, loaded := webhookConfigs["WEBHOOK_TEST"]
+		if loaded != tt.wantLoaded {
+			t.Errorf("%v: channel loaded = %v, want %v", tt.name, loaded, tt.wantLoaded)
+		}
+		if reported := strings.Contains(logs.String(), "WEBHOOK - Invalid channel configuration."); reported == tt.wantLoaded {
+			t.Errorf("%v: error reported = %v, log %q", tt.name, reported, logs.String())
+		}
+		if loaded && (config.bodyTemplate != nil) != (len(config.Body) > 0) {
+			t.Errorf("%v: body template compiled = %v for body %q", tt.name, config.bodyTemplate != nil, config.Body)
+		}
+	}
+}
+
+func TestHasHeader(t *testing.T) {
+	headers := []string{"Authorization: Bearer xyz", "content-type : text/plain"}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "Content-Type", want: true},
+		{name: "authorization", want: true},
+		{name: "Accept", want: false},
+	}
+	for _, tt := range tests {
+		if got := hasHeader(headers, tt.name); got != tt.want {
+			t.Errorf("hasHeader(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
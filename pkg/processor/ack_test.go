@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAcknowledgeAlert(t *testing.T) {
+	firedAt := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		alerts  []Alert
+		ruleID  string
+		wantErr bool
+		wantBy  string
+	}{
+		{name: "active alert", alerts: []Alert{{RuleID: "freezer-stopped", FiredAt: firedAt}}, ruleID: "freezer-stopped", wantBy: "cli"},
+		{name: "unknown rule", alerts: []Alert{{RuleID: "freezer-stopped", FiredAt: firedAt}}, ruleID: "fridge-power", wantErr: true},
+		{name: "alert not fired yet", alerts: []Alert{{RuleID: "freezer-stopped"}}, ruleID: "freezer-stopped", wantErr: true},
+		{name: "already acknowledged", alerts: []Alert{{RuleID: "freezer-stopped", FiredAt: firedAt, AcknowledgedBy: "api"}}, ruleID: "freezer-stopped", wantErr: true, wantBy: "api"},
+		{name: "no alerts of device", ruleID: "freezer-stopped", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}}
+			if tt.alerts != nil {
+				firedAlertStorage.FiredAlerts["plug-freezer"] = tt.alerts
+			}
+
+			err := AcknowledgeAlert("plug-freezer", tt.ruleID, "cli")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AcknowledgeAlert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(tt.alerts) == 0 {
+				return
+			}
+			alert := firedAlertStorage.FiredAlerts["plug-freezer"][0]
+			if alert.AcknowledgedBy != tt.wantBy {
+				t.Errorf("AcknowledgedBy = %q, want %q", alert.AcknowledgedBy, tt.wantBy)
+			}
+			if !tt.wantErr && alert.AcknowledgedAt.IsZero() {
+				t.Error("AcknowledgedAt is not set")
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/mqttclient"
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+const (
+	historyEventAction  = "action"
+	actionResultTimeout = 10 * time.Second
+)
+
+// Waits for stat/<device>/RESULT (or stat/<device>/<command>) after command was sent to device
+type commandResultWaiter struct {
+	device  string
+	command string
+	result  chan string
+}
+
+var (
+	// Used to publish commands of rule actions
+	commandClient     *mqttclient.MqttClient
+	resultWaiters     []*commandResultWaiter
+	resultWaitersLock sync.Mutex
+)
+
+// Run actions of rule in background. Result of every command is verified and reported to rule recipients.
+func runRuleActions(device string, rule ruleengine.Rule, on string) {
+	for _, action := range ruleengine.ActionsForRule(device, rule.ID, on) {
+		go runRuleAction(device, rule, action)
+	}
+}
+
+func runRuleAction(device string, rule ruleengine.Rule, action ruleengine.RuleAction) {
+	var report []string
+	for _, step := range action.Steps {
+		if step.Topic == "" {
+			time.Sleep(step.Delay)
+			report = append(report, fmt.Sprintf("waited %v", step.Delay))
+			continue
+		}
+		result := sendCommandAndWaitForResult(step.Topic, step.Payload)
+		slog.Info("ACTION - Command sent.", "device", device, "rule", rule.ID, "command", step.Topic, "payload", step.Payload, "result", result)
+		report = append(report, fmt.Sprintf("%v %v: %v", step.Topic, step.Payload, result))
+	}
+
+	recordHistoryEvent(HistoryEvent{Device: device, RuleID: rule.ID, Event: historyEventAction, Details: strings.Join(report, "; ")})
+	if len(rule.Recipients) > 0 {
+		emailBody := fmt.Sprintf("Action on %v of alert [ %v ] of [ %v ]:\n- %v", action.On, rule.ID, device, strings.Join(report, "\n- "))
+		alertsLock.Lock()
+		notifyRuleChannels(device, rule, notificationengine.Notification{State: notificationengine.StateAction, Message: emailBody})
+		alertsLock.Unlock()
+	}
+}
+
+// Topic is like cmnd/plug-heater/POWER. Returns description of the result.
+func sendCommandAndWaitForResult(topic string, payload string) string {
+	segments := strings.Split(topic, "/")
+	if len(segments) < 3 {
+		return "invalid command topic"
+	}
+	waiter := &commandResultWaiter{device: segments[1], command: segments[len(segments)-1], result: make(chan string, 1)}
+	resultWaitersLock.Lock()
+	resultWaiters = append(resultWaiters, waiter)
+	resultWaitersLock.Unlock()
+	defer removeResultWaiter(waiter)
+
+	if commandClient == nil {
+		return "FAILED, MQTT client is not available"
+	}
+	if err := commandClient.SendCommand(topic, payload); err != nil {
+		return fmt.Sprintf("FAILED, %v", err)
+	}
+
+	select {
+	case value := <-waiter.result:
+		if len(payload) > 0 && normalizedCommandValue(value) != normalizedCommandValue(payload) {
+			return fmt.Sprintf("NOT confirmed, device reports %v=%v", waiter.command, value)
+		}
+		return fmt.Sprintf("confirmed (%v=%v)", waiter.command, value)
+	case <-time.After(actionResultTimeout):
+		return fmt.Sprintf("NOT confirmed within %v", actionResultTimeout)
+	}
+}
+
+func removeResultWaiter(waiter *commandResultWaiter) {
+	resultWaitersLock.Lock()
+	defer resultWaitersLock.Unlock()
+	for idx, w := range resultWaiters {
+		if w == waiter {
+			resultWaiters = append(resultWaiters[:idx], resultWaiters[idx+1:]...)
+			return
+		}
+	}
+}
+
+// Pass stat/<device>/RESULT {"POWER":"OFF"} or stat/<device>/POWER OFF to commands waiting for result
+func deliverCommandResult(device string, suffix string, messagePayload []byte) {
+	resultWaitersLock.Lock()
+	defer resultWaitersLock.Unlock()
+	if len(resultWaiters) == 0 {
+		return
+	}
+
+	var result map[string]any
+	if suffix == "RESULT" {
+		if err := json.Unmarshal(messagePayload, &result); err != nil {
+			return
+		}
+	} else {
+		result = map[string]any{suffix: strings.TrimSpace(string(messagePayload))}
+	}
+
+	for _, waiter := range resultWaiters {
+		if waiter.device != device {
+			continue
+		}
+		for key, value := range result {
+			if strings.EqualFold(key, waiter.command) {
+				select {
+				case waiter.result <- fmt.Sprintf("%v", value):
+				default:
+				}
+			}
+		}
+	}
+}
+
+// Tasmota accepts 1 / on / true for power commands and reports ON
+func normalizedCommandValue(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "on", "true":
+		return "ON"
+	case "0", "off", "false":
+		return "OFF"
+	}
+	return strings.ToUpper(strings.TrimSpace(value))
+}
@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"testing"
+)
+
+func TestNormalizedCommandValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "1", want: "ON"},
+		{value: "on", want: "ON"},
+		{value: "TRUE", want: "ON"},
+		{value: " 0 ", want: "OFF"},
+		{value: "off", want: "OFF"},
+		{value: "false", want: "OFF"},
+		{value: "toggle", want: "TOGGLE"},
+		{value: "50", want: "50"},
+	}
+	for _, tt := range tests {
+		if got := normalizedCommandValue(tt.value); got != tt.want {
+			t.Errorf("normalizedCommandValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverCommandResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		device  string
+		suffix  string
+		payload string
+		want    string
+	}{
+		{name: "result json", device: "plug", suffix: "RESULT", payload: `{"POWER":"OFF"}`, want: "OFF"},
+		{name: "result json other case", device: "plug", suffix: "RESULT", payload: `{"Power":"ON"}`, want: "ON"},
+		{name: "command topic", device: "plug", suffix: "POWER", payload: "ON ", want: "ON"},
+		{name: "other command", device: "plug", suffix: "RESULT", payload: `{"Dimmer":50}`, want: ""},
+		{name: "other device", device: "lamp", suffix: "POWER", payload: "ON", want: ""},
+		{name: "invalid json", device: "plug", suffix: "RESULT", payload: `{"POWER":`, want: ""},
+	}
+	for _, tt := range tests {
+		waiter := &commandResultWaiter{device: "plug", command: "POWER", result: make(chan string, 1)}
+		resultWaiters = []*commandResultWaiter{waiter}
+		deliverCommandResult(tt.device, tt.suffix, []byte(tt.payload))
+		got := ""
+		select {
+		case got = <-waiter.result:
+		default:
+		}
+		if got != tt.want {
+			t.Errorf("%v: waiter got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	resultWaiters = nil
+}
+
+func TestSendCommandWithoutClient(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{topic: "cmnd/POWER", want: "invalid command topic"},
+		{topic: "cmnd/plug/POWER", want: "FAILED, MQTT client is not available"},
+	}
+	for _, tt := range tests {
+		if got := sendCommandAndWaitForResult(tt.topic, "OFF"); got != tt.want {
+			t.Errorf("sendCommandAndWaitForResult(%q) = %q, want %q", tt.topic, got, tt.want)
+		}
+	}
+	if len(resultWaiters) != 0 {
+		t.Errorf("result waiters were not removed: %v", len(resultWaiters))
+	}
+}
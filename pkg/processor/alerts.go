@@ -4,19 +4,40 @@ import (
 	"encoding/json"
 	"log/slog"
 	"os"
+	"time"
 )
 
 const firedAlertLastStateStorage = "storage/firedAlerts.json"
 
 type Alert struct {
+	RuleID                       string
 	IgnoreCount                  int64
 	AlertJsonPathOrEventTag      string
 	AlertMonitoredActionAndValue string
 	Recipients                   string
+	DeviceValue                  string
+	FiredAt                      time.Time
+	LastNotifiedAt               time.Time
+	RemindersSent                int64
+	AcknowledgedBy               string
+	AcknowledgedAt               time.Time
+	// Firing notification was not sent because alert was silenced or inhibited, it is sent when that is over
+	FiringHeld bool
+	// Receipts of emergency notifications (like Pushover priority 2) by channel, acknowledgement is polled until alert is resolved
+	Receipts map[string]string
+	// Message IDs of firing notification by channel (like Matrix event ID), following notifications of the alert are replies to it
+	ThreadRoots map[string]string
 }
 
 type Alerts struct {
 	FiredAlerts map[string][]Alert
+	// Fire / resolve transitions of alerts with flapping detection, key is device/rule ID
+	FlapStates map[string]FlapState
+}
+
+type FlapState struct {
+	Transitions []time.Time
+	Flapping    bool
 }
 
 func NewAlerts() Alerts {
@@ -27,9 +48,12 @@ func NewAlerts() Alerts {
 	} else {
 		slog.Info("Previously fired alerts loaded.", "file", firedAlertLastStateStorage)
 	}
+	if alerts.FlapStates == nil {
+		alerts.FlapStates = make(map[string]FlapState)
+	}
 
 	slog.Debug("NewAlerts", "alerts", alerts)
-	return Alerts{alerts.FiredAlerts}
+	return Alerts{alerts.FiredAlerts, alerts.FlapStates}
 }
 
 func (alerts Alerts) StoreAlerts() {
@@ -37,20 +61,20 @@ func (alerts Alerts) StoreAlerts() {
 
 	jsonData, err := json.Marshal(alerts)
 	if err != nil {
-		slog.Error("Error encoding JSON when storing fired alerts:", err)
+		slog.Error("Error encoding JSON when storing fired alerts:", "error", err)
 		return
 	}
 
 	file, err := os.Create(firedAlertLastStateStorage)
 	if err != nil {
-		slog.Error("Error creating file when storing fired alerts:", err)
+		slog.Error("Error creating file when storing fired alerts:", "error", err)
 		return
 	}
 	defer file.Close()
 
 	_, err = file.Write(jsonData)
 	if err != nil {
-		slog.Error("Error writing JSON to file when storing fired alerts:", err)
+		slog.Error("Error writing JSON to file when storing fired alerts:", "error", err)
 		return
 	}
 }
@@ -58,7 +82,7 @@ func (alerts Alerts) StoreAlerts() {
 func (alerts Alerts) DumpAlerts() {
 	jsonData, err := json.Marshal(alerts)
 	if err != nil {
-		slog.Error("Error encoding JSON when storing fired alerts:", err)
+		slog.Error("Error encoding JSON when storing fired alerts:", "error", err)
 		return
 	}
 	slog.Info("Dumping already fired alerts.", "ALERTS", jsonData)
@@ -73,7 +97,7 @@ func readAlerts() Alerts {
 
 	file, err := os.Open(firedAlertLastStateStorage)
 	if err != nil {
-		slog.Error("Error opening file with stored alerts:", err)
+		slog.Error("Error opening file with stored alerts:", "error", err)
 		return Alerts{}
 	}
 	defer file.Close()
@@ -81,7 +105,7 @@ func readAlerts() Alerts {
 	var restoredData Alerts
 	err = json.NewDecoder(file).Decode(&restoredData)
 	if err != nil {
-		slog.Error("Error decoding JSON from stored alerts:", err)
+		slog.Error("Error decoding JSON from stored alerts:", "error", err)
 		return Alerts{}
 	}
 
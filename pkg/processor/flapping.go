@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+func flapKey(device string, ruleID string) string {
+	return device + "/" + ruleID
+}
+
+// Record fire / resolve transition of alert. Returns true when alert is flapping and the transition should not be notified.
+// Single notification is sent when alert starts flapping. Must be called with alertsLock held.
+func recordAlertTransition(device string, rule ruleengine.Rule) bool {
+	if rule.FlapThreshold == 0 {
+		return false
+	}
+
+	now := time.Now()
+	key := flapKey(device, rule.ID)
+	state := firedAlertStorage.FlapStates[key]
+	state.Transitions = append(transitionsInWindow(state.Transitions, now, rule.FlapWindow), now)
+	defer func() { firedAlertStorage.FlapStates[key] = state }()
+
+	if state.Flapping {
+		slog.Debug("FLAPPING - Transition not notified.", "device", device, "rule", rule.ID, "transitions", len(state.Transitions))
+		return true
+	}
+	if int64(len(state.Transitions)) >= rule.FlapThreshold {
+		state.Flapping = true
+		slog.Info("FLAPPING - Alert started flapping.", "device", device, "rule", rule.ID, "transitions", len(state.Transitions))
+		emailBody := fmt.Sprintf("Alert [ %v ] of [ %v ] is flapping. State changed %v times in last %v. Notifications are suppressed until it is stable again.", rule.ID, device, len(state.Transitions), rule.FlapWindow)
+		notifyRuleChannels(device, rule, notificationengine.Notification{State: notificationengine.StateFlapping, Message: emailBody})
+		return true
+	}
+	return false
+}
+
+func isAlertFlapping(device string, ruleID string) bool {
+	return firedAlertStorage.FlapStates[flapKey(device, ruleID)].Flapping
+}
+
+// Alert is stable again when transitions in window drop below half of the threshold
+func (p *Processor) checkFlappingAlerts() {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+
+	now := time.Now()
+	rules := ruleengine.MonitoringRulesByDevice()
+	for key, state := range firedAlertStorage.FlapStates {
+		device, rule, found := ruleForFlapKey(rules, key)
+		if !found || rule.FlapThreshold == 0 {
+			delete(firedAlertStorage.FlapStates, key)
+			continue
+		}
+
+		state.Transitions = transitionsInWindow(state.Transitions, now, rule.FlapWindow)
+		if state.Flapping && int64(len(state.Transitions))*2 < rule.FlapThreshold {
+			state.Flapping = false
+			currentState := "resolved"
+			if isAlertFiring(device, rule.ID) {
+				currentState = "firing"
+			}
+			slog.Info("FLAPPING - Alert is stable again.", "device", device, "rule", rule.ID, "state", currentState)
+			emailBody := fmt.Sprintf("Alert [ %v ] of [ %v ] is stable again. Current state is %v.", rule.ID, device, currentState)
+			notifyRuleChannels(device, rule, notificationengine.Notification{State: notificationengine.StateStable, Message: emailBody})
+		}
+
+		if !state.Flapping && len(state.Transitions) == 0 {
+			delete(firedAlertStorage.FlapStates, key)
+		} else {
+			firedAlertStorage.FlapStates[key] = state
+		}
+	}
+}
+
+func ruleForFlapKey(rules map[string][]ruleengine.Rule, key string) (string, ruleengine.Rule, bool) {
+	for device, rulesForDevice := range rules {
+		for _, rule := range rulesForDevice {
+			if flapKey(device, rule.ID) == key {
+				return device, rule, true
+			}
+		}
+	}
+	return "", ruleengine.Rule{}, false
+}
+
+func transitionsInWindow(transitions []time.Time, now time.Time, window time.Duration) []time.Time {
+	var inWindow []time.Time
+	for _, transition := range transitions {
+		if now.Sub(transition) <= window {
+			inWindow = append(inWindow, transition)
+		}
+	}
+	return inWindow
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+func TestTransitionsInWindow(t *testing.T) {
+	now := time.Now()
+	transitions := []time.Time{now.Add(-2 * time.Hour), now.Add(-time.Hour), now.Add(-time.Minute), now}
+	tests := []struct {
+		window time.Duration
+		want   int
+	}{
+		{window: 3 * time.Hour, want: 4},
+		{window: time.Hour, want: 3},
+		{window: 10 * time.Minute, want: 2},
+		{window: 0, want: 1},
+	}
+	for _, tt := range tests {
+		if got := transitionsInWindow(transitions, now, tt.window); len(got) != tt.want {
+			t.Errorf("transitionsInWindow(%v) returned %v transitions, want %v", tt.window, len(got), tt.want)
+		}
+	}
+}
+
+func TestRecordAlertTransition(t *testing.T) {
+	tests := []struct {
+		name           string
+		rule           ruleengine.Rule
+		transitions    int
+		wantFlapping   bool
+		wantNotified   int
+		wantSuppressed int
+	}{
+		{name: "detection disabled", rule: ruleengine.Rule{ID: "power", Recipients: "TEST"}, transitions: 10},
+		{name: "below threshold", rule: ruleengine.Rule{ID: "power", Recipients: "TEST", FlapThreshold: 4, FlapWindow: time.Hour}, transitions: 3},
+		{name: "at threshold", rule: ruleengine.Rule{ID: "power", Recipients: "TEST", FlapThreshold: 4, FlapWindow: time.Hour}, transitions: 4,
+			wantFlapping: true, wantNotified: 1, wantSuppressed: 1},
+		{name: "over threshold", rule: ruleengine.Rule{ID: "power", Recipients: "TEST", FlapThreshold: 4, FlapWindow: time.Hour}, transitions: 7,
+			wantFlapping: true, wantNotified: 1, wantSuppressed: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}, FlapStates: map[string]FlapState{}}
+			notificationQueue = nil
+
+			suppressed := 0
+			for i := 0; i < tt.transitions; i++ {
+				if recordAlertTransition("plug-fridge", tt.rule) {
+					suppressed++
+				}
+			}
+			if suppressed != tt.wantSuppressed {
+				t.Errorf("suppressed %v transitions, want %v", suppressed, tt.wantSuppressed)
+			}
+			if got := isAlertFlapping("plug-fridge", tt.rule.ID); got != tt.wantFlapping {
+				t.Errorf("isAlertFlapping() = %v, want %v", got, tt.wantFlapping)
+			}
+			if len(notificationQueue) != tt.wantNotified {
+				t.Fatalf("queued %v notifications, want %v", len(notificationQueue), tt.wantNotified)
+			}
+			for _, queued := range notificationQueue {
+				if queued.notification.State != notificationengine.StateFlapping || queued.notification.Device != "plug-fridge" {
+					t.Errorf("unexpected notification %+v", queued.notification)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckFlappingAlerts(t *testing.T) {
+	loadTestRules(t, "0:::plug-fridge:::ENERGY-->Power:::<1:::TEST::::::::::::id=power:::flap=4/1h")
+	t.Cleanup(func() { loadTestRules(t) })
+	now := time.Now()
+	tests := []struct {
+		name         string
+		key          string
+		state        FlapState
+		wantState    bool
+		wantFlapping bool
+		wantNotified bool
+	}{
+		{name: "still flapping", key: flapKey("plug-fridge", "power"), state: FlapState{Transitions: []time.Time{now, now, now}, Flapping: true},
+			wantState: true, wantFlapping: true},
+		{name: "stable again", key: flapKey("plug-fridge", "power"), state: FlapState{Transitions: []time.Time{now}, Flapping: true},
+			wantState: true, wantNotified: true},
+		{name: "transitions out of window", key: flapKey("plug-fridge", "power"), state: FlapState{Transitions: []time.Time{now.Add(-2 * time.Hour)}}},
+		{name: "rule removed", key: flapKey("plug-fridge", "old"), state: FlapState{Transitions: []time.Time{now, now, now}, Flapping: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}, FlapStates: map[string]FlapState{tt.key: tt.state}}
+			notificationQueue = nil
+
+			(&Processor{}).checkFlappingAlerts()
+
+			state, found := firedAlertStorage.FlapStates[tt.key]
+			if found != tt.wantState || state.Flapping != tt.wantFlapping {
+				t.Errorf("state found %v flapping %v, want found %v flapping %v", found, state.Flapping, tt.wantState, tt.wantFlapping)
+			}
+			if notified := len(notificationQueue) == 1 && notificationQueue[0].notification.State == notificationengine.StateStable; notified != tt.wantNotified {
+				t.Errorf("stable notification sent %v, want %v", notified, tt.wantNotified)
+			}
+		})
+	}
+}
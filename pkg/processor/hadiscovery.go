@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+// Broker publishes "offline" to this topic when alerter disconnects (LWT), "online" after connect
+const AlerterAvailabilityTopic = "tasmota-alerter/status"
+
+type haDiscoveryConfig struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	ObjectID          string   `json:"object_id"`
+	StateTopic        string   `json:"state_topic"`
+	PayloadOn         string   `json:"payload_on"`
+	PayloadOff        string   `json:"payload_off"`
+	DeviceClass       string   `json:"device_class"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	Device            haDevice `json:"device"`
+}
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+}
+
+var (
+	// Empty prefix means discovery is disabled
+	haDiscoveryPrefix string
+	// Config topics published last time, configs of removed rules are deleted after reload.
+	// Retained configs published before restart are learned from broker.
+	haPublishedConfigs = map[string]bool{}
+	haLock             sync.Mutex
+	haInvalidIdChars   = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+)
+
+// Publish Home Assistant MQTT discovery configs (binary sensor per device and rule) and current alert states
+func EnableHaDiscovery(prefix string) error {
+	haLock.Lock()
+	haDiscoveryPrefix = strings.TrimSuffix(prefix, "/")
+	haLock.Unlock()
+	publishHaDiscovery()
+
+	if len(haDiscoveryPrefix) == 0 || commandClient == nil {
+		return nil
+	}
+	// Broker sends retained configs after subscribe, configs of rules removed while alerter was not running are deleted
+	configTopics := haDiscoveryPrefix + "/binary_sensor/+/+/config"
+	if err := commandClient.Subscribe(configTopics, removeStaleHaConfig); err != nil {
+		return fmt.Errorf("can't subscribe to %q: %w", configTopics, err)
+	}
+	return nil
+}
+
+func removeStaleHaConfig(_ mqtt.Client, m mqtt.Message) {
+	haLock.Lock()
+	defer haLock.Unlock()
+	if isStaleHaConfig(m.Topic(), m.Payload()) {
+		slog.Info("Removing Home Assistant discovery config of removed rule.", "topic", m.Topic())
+		commandClient.PublishAsync(m.Topic(), 1, true, []byte{})
+	}
+}
+
+// Config of alerter which is not published anymore. Must be called with haLock held.
+func isStaleHaConfig(configTopic string, payload []byte) bool {
+	// Empty config is already removed, configs of other integrations are kept
+	if len(payload) == 0 || haPublishedConfigs[configTopic] {
+		return false
+	}
+	nodeId := strings.TrimPrefix(configTopic, haDiscoveryPrefix+"/binary_sensor/")
+	return strings.HasPrefix(nodeId, haObjectId("", "")+"_")
+}
+
+func publishHaDiscovery() {
+	rulesByDevice := ruleengine.MonitoringRulesByDevice()
+	// Alerts are locked before haLock everywhere
+	firing := map[string]bool{}
+	alertsLock.Lock()
+	for device, rules := range rulesByDevice {
+		for _, rule := range rules {
+			for _, alert := range firedAlertStorage.FiredAlerts[device] {
+				if isAlertForRule(alert, rule) && !alert.FiredAt.IsZero() {
+					firing[device+"/"+rule.ID] = true
+				}
+			}
+		}
+	}
+	alertsLock.Unlock()
+
+	haLock.Lock()
+	defer haLock.Unlock()
+	if len(haDiscoveryPrefix) == 0 || commandClient == nil {
+		return
+	}
+
+	published := map[string]bool{}
+	for device, rules := range rulesByDevice {
+		for _, rule := range rules {
+			// Events have no state
+			if strings.HasPrefix(rule.CompareValue, "/") {
+				continue
+			}
+			objectId := haObjectId(device, rule.ID)
+			config := haDiscoveryConfig{
+				Name:              rule.ID,
+				UniqueID:          objectId,
+				ObjectID:          objectId,
+				StateTopic:        haStateTopic(device, rule.ID),
+				PayloadOn:         "ON",
+				PayloadOff:        "OFF",
+				DeviceClass:       "problem",
+				AvailabilityTopic: AlerterAvailabilityTopic,
+				Device: haDevice{
+					Identifiers:  []string{haObjectId(device, "")},
+					Name:         device + " alerts",
+					Manufacturer: "tasmota-alerter",
+				},
+			}
+			jsonData, err := json.Marshal(config)
+			if err != nil {
+				slog.Error("Error encoding Home Assistant discovery config.", "error", err)
+				continue
+			}
+			configTopic := fmt.Sprintf("%v/binary_sensor/%v/%v/config", haDiscoveryPrefix, haObjectId(device, ""), objectId)
+			commandClient.PublishAsync(configTopic, 1, true, jsonData)
+			published[configTopic] = true
+			publishHaStateLocked(device, rule.ID, firing[device+"/"+rule.ID])
+		}
+	}
+	// Empty retained config removes entity from Home Assistant
+	for configTopic := range haPublishedConfigs {
+		if !published[configTopic] {
+			commandClient.PublishAsync(configTopic, 1, true, []byte{})
+		}
+	}
+	haPublishedConfigs = published
+	slog.Info("Home Assistant discovery published.", "prefix", haDiscoveryPrefix, "entities", len(published))
+}
+
+// Must be called with alertsLock held
+func publishHaState(device string, ruleID string, firing bool) {
+	haLock.Lock()
+	defer haLock.Unlock()
+	publishHaStateLocked(device, ruleID, firing)
+}
+
+// Must be called with haLock held
+func publishHaStateLocked(device string, ruleID string, firing bool) {
+	if len(haDiscoveryPrefix) == 0 || commandClient == nil {
+		return
+	}
+	state := "OFF"
+	if firing {
+		state = "ON"
+	}
+	commandClient.PublishAsync(haStateTopic(device, ruleID), 1, true, []byte(state))
+}
+
+func haStateTopic(device string, ruleID string) string {
+	return fmt.Sprintf("tasmota-alerter/ha/%v/%v/state", haObjectId(device, ""), haObjectId(device, ruleID))
+}
+
+// Rule ID like ENERGY-->Power>1500 is not valid in discovery topic
+func haObjectId(device string, ruleID string) string {
+	id := "tasmota_alerter_" + device
+	if len(ruleID) > 0 {
+		id += "_" + ruleID
+	}
+	return strings.Trim(haInvalidIdChars.ReplaceAllString(id, "_"), "_")
+}
@@ -0,0 +1,77 @@
+package processor
+
+import "testing"
+
+func TestHaObjectId(t *testing.T) {
+	tests := []struct {
+		device string
+		ruleID string
+		want   string
+	}{
+		{device: "plug-fridge", want: "tasmota_alerter_plug-fridge"},
+		{device: "plug-fridge", ruleID: "offline", want: "tasmota_alerter_plug-fridge_offline"},
+		{device: "plug-fridge", ruleID: "ENERGY-->Power>1500", want: "tasmota_alerter_plug-fridge_ENERGY--_Power_1500"},
+		{device: "plug fridge", ruleID: "power>", want: "tasmota_alerter_plug_fridge_power"},
+	}
+	for _, tt := range tests {
+		if got := haObjectId(tt.device, tt.ruleID); got != tt.want {
+			t.Errorf("haObjectId(%q, %q) = %v, want %v", tt.device, tt.ruleID, got, tt.want)
+		}
+	}
+}
+
+func TestHaStateTopic(t *testing.T) {
+	tests := []struct {
+		device string
+		ruleID string
+		want   string
+	}{
+		{device: "plug-fridge", ruleID: "offline", want: "tasmota-alerter/ha/tasmota_alerter_plug-fridge/tasmota_alerter_plug-fridge_offline/state"},
+		{device: "plug-fridge", ruleID: "ENERGY-->Power<1", want: "tasmota-alerter/ha/tasmota_alerter_plug-fridge/tasmota_alerter_plug-fridge_ENERGY--_Power_1/state"},
+	}
+	for _, tt := range tests {
+		if got := haStateTopic(tt.device, tt.ruleID); got != tt.want {
+			t.Errorf("haStateTopic(%q, %q) = %v, want %v", tt.device, tt.ruleID, got, tt.want)
+		}
+	}
+}
+
+func TestPublishHaDiscoveryDisabled(t *testing.T) {
+	loadTestRules(t, "0:::plug-fridge:::ENERGY-->Power:::<1:::TEST")
+	t.Cleanup(func() { loadTestRules(t) })
+	haPublishedConfigs = map[string]bool{}
+	// Without prefix nothing is published, even without MQTT client
+	if err := EnableHaDiscovery(""); err != nil {
+		t.Errorf("EnableHaDiscovery() error = %v", err)
+	}
+	if len(haPublishedConfigs) != 0 {
+		t.Errorf("published configs = %v, want none", haPublishedConfigs)
+	}
+}
+
+func TestIsStaleHaConfig(t *testing.T) {
+	haDiscoveryPrefix = "homeassistant"
+	haPublishedConfigs = map[string]bool{"homeassistant/binary_sensor/tasmota_alerter_plug-fridge/tasmota_alerter_plug-fridge_offline/config": true}
+	t.Cleanup(func() {
+		haDiscoveryPrefix = ""
+		haPublishedConfigs = map[string]bool{}
+	})
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		want    bool
+	}{
+		{name: "published config", topic: "homeassistant/binary_sensor/tasmota_alerter_plug-fridge/tasmota_alerter_plug-fridge_offline/config", payload: "{}"},
+		{name: "config of removed rule", topic: "homeassistant/binary_sensor/tasmota_alerter_plug-fridge/tasmota_alerter_plug-fridge_power/config", payload: "{}", want: true},
+		{name: "config of removed device", topic: "homeassistant/binary_sensor/tasmota_alerter_plug-lamp/tasmota_alerter_plug-lamp_offline/config", payload: "{}", want: true},
+		{name: "already removed", topic: "homeassistant/binary_sensor/tasmota_alerter_plug-lamp/tasmota_alerter_plug-lamp_offline/config"},
+		{name: "other integration", topic: "homeassistant/binary_sensor/tasmota_plug-lamp/plug-lamp_power/config", payload: "{}"},
+		{name: "other prefix", topic: "other/binary_sensor/tasmota_alerter_plug-lamp/tasmota_alerter_plug-lamp_offline/config", payload: "{}"},
+	}
+	for _, tt := range tests {
+		if got := isStaleHaConfig(tt.topic, []byte(tt.payload)); got != tt.want {
+			t.Errorf("%v: isStaleHaConfig() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	alertHistoryStorage = "storage/alertHistory.json"
+	// Events older than this are removed from history
+	alertHistoryRetention = 31 * 24 * time.Hour
+	// Changed history is stored also while running, not only on exit
+	alertHistoryStoreInterval = time.Hour
+)
+
+const (
+	historyEventFired    = "fired"
+	historyEventResolved = "resolved"
+	historyEventOffline  = "offline"
+)
+
+type HistoryEvent struct {
+	Time   time.Time
+	Device string
+	RuleID string
+	Event  string
+	// How long alert was active, set for resolved alerts
+	Duration time.Duration
+	Details  string
+}
+
+type AlertHistory struct {
+	Events []HistoryEvent
+}
+
+var (
+	alertHistory AlertHistory
+	historyLock  sync.Mutex
+	// History has events not written to storage yet
+	historyChanged  bool
+	historyStoredAt = time.Now()
+)
+
+func recordHistoryEvent(event HistoryEvent) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	slog.Debug("HISTORY", "event", event)
+
+	// Events are added in time order, old events are at the beginning
+	keepFrom := 0
+	for keepFrom < len(alertHistory.Events) && event.Time.Sub(alertHistory.Events[keepFrom].Time) > alertHistoryRetention {
+		keepFrom++
+	}
+	alertHistory.Events = append(alertHistory.Events[keepFrom:], event)
+	historyChanged = true
+}
+
+func historyEventsSince(since time.Time) []HistoryEvent {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	var events []HistoryEvent
+	for _, event := range alertHistory.Events {
+		if event.Time.After(since) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Store history when it changed and store interval elapsed
+func storeAlertHistoryPeriodically(now time.Time) {
+	historyLock.Lock()
+	due := historyChanged && now.Sub(historyStoredAt) >= alertHistoryStoreInterval
+	historyLock.Unlock()
+	if due {
+		StoreAlertHistory()
+	}
+}
+
+func StoreAlertHistory() {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+
+	historyChanged = false
+	historyStoredAt = time.Now()
+
+	slog.Info("Storing alert history.", "file", alertHistoryStorage)
+	jsonData, err := json.Marshal(alertHistory)
+	if err != nil {
+		slog.Error("Error encoding JSON when storing alert history.", "error", err)
+		return
+	}
+	if err := os.WriteFile(alertHistoryStorage, jsonData, 0644); err != nil {
+		slog.Error("Error writing alert history to file.", "file", alertHistoryStorage, "error", err)
+	}
+}
+
+func readAlertHistory() AlertHistory {
+	jsonData, err := os.ReadFile(alertHistoryStorage)
+	if err != nil {
+		slog.Debug("File with alert history does not exist or is not readable.")
+		return AlertHistory{}
+	}
+	var restoredData AlertHistory
+	if err := json.Unmarshal(jsonData, &restoredData); err != nil {
+		slog.Error("Error decoding JSON from alert history.", "error", err)
+		return AlertHistory{}
+	}
+	slog.Info("Alert history loaded.", "file", alertHistoryStorage, "events", len(restoredData.Events))
+	return restoredData
+}
@@ -0,0 +1,32 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryEventsSince(t *testing.T) {
+	t.Cleanup(func() { alertHistory = AlertHistory{} })
+	alertHistory = AlertHistory{}
+	now := time.Now()
+	recordHistoryEvent(HistoryEvent{Time: now.Add(-40 * 24 * time.Hour), Device: "old", Event: historyEventFired})
+	recordHistoryEvent(HistoryEvent{Time: now.Add(-2 * time.Hour), Device: "plug", Event: historyEventFired})
+	recordHistoryEvent(HistoryEvent{Time: now.Add(-time.Hour), Device: "plug", Event: historyEventResolved})
+
+	if len(alertHistory.Events) != 2 {
+		t.Fatalf("history has %v events, want 2 after removing events older than retention", len(alertHistory.Events))
+	}
+	tests := []struct {
+		since time.Time
+		want  int
+	}{
+		{since: now.Add(-3 * time.Hour), want: 2},
+		{since: now.Add(-90 * time.Minute), want: 1},
+		{since: now, want: 0},
+	}
+	for _, tt := range tests {
+		if got := historyEventsSince(tt.since); len(got) != tt.want {
+			t.Errorf("historyEventsSince(%v) returned %v events, want %v", tt.since, len(got), tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+// Returns description of firing alert which suppresses notifications of rule on device, empty when there is none.
+// Must be called with alertsLock held.
+func inhibitingAlert(device string, rule ruleengine.Rule) string {
+	// Rule depends on another rule of the same device, like value rules on availability rule
+	if len(rule.DependsOn) > 0 && isAlertFiring(device, rule.DependsOn) {
+		return fmt.Sprintf("rule %q depends on firing alert %q of device %q", rule.ID, rule.DependsOn, device)
+	}
+
+	for _, inhibit := range ruleengine.InhibitRules() {
+		if !globMatches(inhibit.TargetRuleID, rule.ID) {
+			continue
+		}
+		for sourceDevice, storedAlerts := range firedAlertStorage.FiredAlerts {
+			if !globMatches(inhibit.SourceDevice, sourceDevice) {
+				continue
+			}
+			if inhibit.TargetDevice == ruleengine.InhibitSameDeviceTag {
+				if sourceDevice != device {
+					continue
+				}
+			} else if !globMatches(inhibit.TargetDevice, device) {
+				continue
+			}
+			for _, alert := range storedAlerts {
+				// Alert never inhibits itself
+				if sourceDevice == device && alert.RuleID == rule.ID {
+					continue
+				}
+				if alert.RuleID == inhibit.SourceRuleID && !alert.FiredAt.IsZero() {
+					return fmt.Sprintf("inhibited by firing alert %q of device %q", alert.RuleID, sourceDevice)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func isAlertFiring(device string, ruleID string) bool {
+	for _, alert := range firedAlertStorage.FiredAlerts[device] {
+		if alert.RuleID == ruleID && !alert.FiredAt.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func globMatches(pattern string, value string) bool {
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+func TestInhibitingAlert(t *testing.T) {
+	loadTestRules(t,
+		"__INHIBIT__:::plug-*:::offline:::__SAME__:::*",
+		"__INHIBIT__:::plug-main-breaker:::breaker-tripped:::plug-*:::power-*",
+	)
+	t.Cleanup(func() { loadTestRules(t) })
+	firedAt := time.Now()
+
+	tests := []struct {
+		name          string
+		firing        map[string][]Alert
+		device        string
+		rule          ruleengine.Rule
+		wantInhibited bool
+	}{
+		{name: "nothing fires", device: "plug-fridge", rule: ruleengine.Rule{ID: "power-high"}},
+		{name: "same device offline", firing: map[string][]Alert{"plug-fridge": {{RuleID: "offline", FiredAt: firedAt}}},
+			device: "plug-fridge", rule: ruleengine.Rule{ID: "power-high"}, wantInhibited: true},
+		{name: "other device offline", firing: map[string][]Alert{"plug-freezer": {{RuleID: "offline", FiredAt: firedAt}}},
+			device: "plug-fridge", rule: ruleengine.Rule{ID: "power-high"}},
+		{name: "source alert not fired yet", firing: map[string][]Alert{"plug-fridge": {{RuleID: "offline"}}},
+			device: "plug-fridge", rule: ruleengine.Rule{ID: "power-high"}},
+		{name: "alert does not inhibit itself", firing: map[string][]Alert{"plug-fridge": {{RuleID: "offline", FiredAt: firedAt}}},
+			device: "plug-fridge", rule: ruleengine.Rule{ID: "offline"}},
+		{name: "breaker inhibits power rules of plugs", firing: map[string][]Alert{"plug-main-breaker": {{RuleID: "breaker-tripped", FiredAt: firedAt}}},
+			device: "plug-fridge", rule: ruleengine.Rule{ID: "power-low"}, wantInhibited: true},
+		{name: "breaker does not inhibit other rules", firing: map[string][]Alert{"plug-main-breaker": {{RuleID: "breaker-tripped", FiredAt: firedAt}}},
+			device: "plug-fridge", rule: ruleengine.Rule{ID: "door-open"}},
+		{name: "breaker does not inhibit other devices", firing: map[string][]Alert{"plug-main-breaker": {{RuleID: "breaker-tripped", FiredAt: firedAt}}},
+			device: "switch-garage", rule: ruleengine.Rule{ID: "power-low"}},
+		{name: "depends on firing alert", firing: map[string][]Alert{"switch-garage": {{RuleID: "door", FiredAt: firedAt}}},
+			device: "switch-garage", rule: ruleengine.Rule{ID: "light", DependsOn: "door"}, wantInhibited: true},
+		{name: "depends on alert of other device", firing: map[string][]Alert{"switch-cellar": {{RuleID: "door", FiredAt: firedAt}}},
+			device: "switch-garage", rule: ruleengine.Rule{ID: "light", DependsOn: "door"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firedAlertStorage = Alerts{FiredAlerts: tt.firing}
+			if got := inhibitingAlert(tt.device, tt.rule); (len(got) > 0) != tt.wantInhibited {
+				t.Errorf("inhibitingAlert() = %q, want inhibited %v", got, tt.wantInhibited)
+			}
+		})
+	}
+}
+
+func TestInhibitedFiringIsHeldBack(t *testing.T) {
+	loadTestRules(t,
+		"__INHIBIT__:::plug-*:::offline:::__SAME__:::*",
+		"0:::plug-fridge:::__AVAILABILITY__:::=Offline:::TEST:::Fridge is offline.:::Fridge is online.:::id=offline",
+		"0:::plug-fridge:::ENERGY-->Power:::<1:::TEST:::Fridge is not running.:::Fridge is running.:::id=power-low",
+	)
+	t.Cleanup(func() { loadTestRules(t) })
+	rules := ruleengine.MonitoringRulesByDevice()["plug-fridge"]
+	offline, powerLow := rules[0], rules[1]
+
+	tests := []struct {
+		name      string
+		resolve   ruleengine.Rule
+		wantState []string
+		wantRule  []string
+	}{
+		{name: "source resolves first", resolve: offline,
+			wantState: []string{notificationengine.StateResolved, notificationengine.StateFiring}, wantRule: []string{"offline", "power-low"}},
+		{name: "inhibited alert resolves first", resolve: powerLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}, FlapStates: map[string]FlapState{}}
+			silenceStorage = Silences{}
+			notificationQueue = nil
+
+			notifyMonitoredValueArrived("plug-fridge", "Offline", offline)
+			notifyMonitoredValueArrived("plug-fridge", "0.000", powerLow)
+			if len(notificationQueue) != 1 || notificationQueue[0].notification.RuleID != "offline" {
+				t.Fatalf("queued notifications = %+v, want only firing of offline", notificationQueue)
+			}
+			if alert := firedAlertStorage.FiredAlerts["plug-fridge"][1]; !alert.FiringHeld {
+				t.Fatalf("firing of inhibited alert is not held back: %+v", alert)
+			}
+			notificationQueue = nil
+
+			removeAlertIfNotifiedBefore("plug-fridge", "1.000", tt.resolve)
+
+			if len(notificationQueue) != len(tt.wantState) {
+				t.Fatalf("queued notifications = %+v, want states %v", notificationQueue, tt.wantState)
+			}
+			for idx, queued := range notificationQueue {
+				if queued.notification.State != tt.wantState[idx] || queued.notification.RuleID != tt.wantRule[idx] {
+					t.Errorf("notification %v is %v of %q, want %v of %q", idx, queued.notification.State, queued.notification.RuleID, tt.wantState[idx], tt.wantRule[idx])
+				}
+			}
+			for _, alert := range firedAlertStorage.FiredAlerts["plug-fridge"] {
+				if alert.FiringHeld {
+					t.Errorf("alert %q is still held back", alert.RuleID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+)
+
+type queuedNotification struct {
+	recipients   string
+	notification notificationengine.Notification
+}
+
+var (
+	// Notifications of alerts are created while alerts are locked and sent later by single worker in order
+	notificationQueue      []queuedNotification
+	notificationQueueLock  sync.Mutex
+	notificationQueueReady = make(chan struct{}, 1)
+)
+
+// Does not block, so slow notification channel does not stop processing of MQTT messages
+func queueNotification(recipients string, notification notificationengine.Notification) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+	notificationQueueLock.Lock()
+	notificationQueue = append(notificationQueue, queuedNotification{recipients, notification})
+	notificationQueueLock.Unlock()
+
+	select {
+	case notificationQueueReady <- struct{}{}:
+	default:
+	}
+}
+
+func sendQueuedNotifications() {
+	for range notificationQueueReady {
+		for {
+			notificationQueueLock.Lock()
+			if len(notificationQueue) == 0 {
+				notificationQueueLock.Unlock()
+				break
+			}
+			queued := notificationQueue[0]
+			notificationQueue = notificationQueue[1:]
+			notificationQueueLock.Unlock()
+
+			notificationengine.NotifyChannels(queued.recipients, queued.notification)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package processor
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,7 +18,11 @@ import (
 	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
 )
 
-const ruleNotificationSytemTag = "__SYSTEM__"
+const (
+	ruleNotificationSytemTag  = "__SYSTEM__"
+	availabilityRuleTag       = "__AVAILABILITY__"
+	activeAlertsCheckInterval = time.Minute
+)
 
 type Processor struct {
 	scheduled           map[string]any
@@ -29,26 +35,75 @@ type Processor struct {
 
 var (
 	firedAlertStorage Alerts
+	alertsLock        sync.Mutex
 )
 
 func NewProcessor(mqttClient *mqttclient.MqttClient, statusUpdateSeconds int, smtpServer string) *Processor {
 	firedAlertStorage = NewAlerts()
+	silenceStorage = readSilences()
+	alertHistory = readAlertHistory()
+	deviceStats = readDeviceStats()
+	safetyStorage = readSafetyStates()
 	notificationengine.SetupChannels(smtpServer)
-	return &Processor{map[string]any{}, &sync.Mutex{}, mqttClient, statusUpdateSeconds, &parser.JSONParser{}, ruleengine.NewRules()}
+	notificationengine.SetReceiptHandler(storeChannelReceipt)
+	notificationengine.SetThreadRootHandler(storeChannelThreadRoot)
+	notificationengine.SetMqttClient(mqttClient)
+	commandClient = mqttClient
+	p := &Processor{map[string]any{}, &sync.Mutex{}, mqttClient, statusUpdateSeconds, &parser.JSONParser{}, ruleengine.NewRules()}
+	go p.watchActiveAlerts()
+	go sendQueuedNotifications()
+	return p
 }
 
 func RefreshAlertRules() {
 	ruleengine.RefreshRules()
+	publishHaDiscovery()
 }
 
 func StoreFiredAlerts() {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
 	firedAlertStorage.StoreAlerts()
 }
 
 func DumpFiredAlerts() {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
 	firedAlertStorage.DumpAlerts()
 }
 
+// Acknowledged alert stays active until it is resolved, but no more reminders are sent
+func AcknowledgeAlert(device string, ruleID string, user string) error {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+
+	acknowledged := 0
+	for idx, alert := range firedAlertStorage.FiredAlerts[device] {
+		if alert.RuleID != ruleID || alert.FiredAt.IsZero() {
+			continue
+		}
+		if len(alert.AcknowledgedBy) > 0 {
+			return fmt.Errorf("alert %q of device %q is already acknowledged by %v", ruleID, device, alert.AcknowledgedBy)
+		}
+		firedAlertStorage.FiredAlerts[device][idx].AcknowledgedBy = user
+		firedAlertStorage.FiredAlerts[device][idx].AcknowledgedAt = time.Now()
+		cancelReceipts(alertReceipts(device, alert))
+		firedAlertStorage.FiredAlerts[device][idx].Receipts = nil
+		acknowledged++
+		slog.Info("ALERT - Acknowledged.", "device", device, "rule", ruleID, "user", user)
+	}
+	if acknowledged == 0 {
+		return fmt.Errorf("no active alert %q found for device %q", ruleID, device)
+	}
+	return nil
+}
+
+func FiredAlertsAsJson() ([]byte, error) {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	return json.Marshal(firedAlertStorage)
+}
+
 func (p *Processor) Subscribe(mqttListenTopics []string) error {
 	for _, topic := range mqttListenTopics {
 		if err := p.mqttClient.Subscribe(topic, p.messageProcessor); err != nil {
@@ -68,22 +123,52 @@ func (p *Processor) messageProcessor(_ mqtt.Client, m mqtt.Message) {
 		p.scheduleStatusCommand(m.Topic())
 	}
 
-	// Process message topic & payload but ignore Keep-Alive messages
-	if !strings.HasSuffix(m.Topic(), "/LWT") {
+	topicParts := strings.Split(m.Topic(), "/")
+	if len(topicParts) > 2 {
+		// Topic is 3-parts like: tele/plug_washing-machine/SENSOR
+		deviceTopic := topicParts[1]
+		// Data for scheduled reports are collected from all devices
+		if strings.HasSuffix(m.Topic(), "/LWT") {
+			if strings.TrimSpace(string(m.Payload())) == "Offline" {
+				recordHistoryEvent(HistoryEvent{Device: deviceTopic, Event: historyEventOffline})
+			}
+		} else {
+			recordEnergyStats(deviceTopic, m.Payload())
+			p.checkSafetyRules(deviceTopic, m.Payload())
+		}
+		if topicParts[0] == "stat" {
+			deliverCommandResult(deviceTopic, topicParts[len(topicParts)-1], m.Payload())
+		}
 
-		topicParts := strings.Split(m.Topic(), "/")
-		if len(topicParts) > 2 {
-			// Topic is 3-parts like: tele/plug_washing-machine/SENSOR
-			deviceTopic := topicParts[1]
-			monitoringRulesForDevice := p.ruleEngineRules.MonitoringRules[deviceTopic]
-			if len(monitoringRulesForDevice) > 0 {
-				// Any monitoring rules found for this device
+		monitoringRulesForDevice := p.ruleEngineRules.MonitoringRules[deviceTopic]
+		if len(monitoringRulesForDevice) > 0 {
+			// Any monitoring rules found for this device
+			if strings.HasSuffix(m.Topic(), "/LWT") {
+				// Keep-Alive messages (Online / Offline) are used only by availability rules
+				compareAvailabilityRulesWithPayload(deviceTopic, monitoringRulesForDevice, m.Payload())
+			} else {
 				p.compareRulesWithPayload(topicParts, monitoringRulesForDevice, m.Payload())
 			}
 		}
 	}
 }
 
+// Availability rules compare device LWT payload, like =Offline
+func compareAvailabilityRulesWithPayload(deviceTopic string, rulesForDevice []ruleengine.Rule, messagePayload []byte) {
+	deviceValue := strings.TrimSpace(string(messagePayload))
+	for _, rule := range rulesForDevice {
+		if rule.JsonPathOrEventTag != availabilityRuleTag || len(rule.CompareValue) < 2 {
+			continue
+		}
+		slog.Debug("DEBUG - Comparing device availability with rule", "topic", deviceTopic, "deviceValue", deviceValue, "rule_value", rule.CompareValue)
+		if rule.CompareValue[1:] == deviceValue {
+			notifyMonitoredValueArrived(deviceTopic, deviceValue, rule)
+		} else {
+			removeAlertIfNotifiedBefore(deviceTopic, deviceValue, rule)
+		}
+	}
+}
+
 func (p *Processor) compareRulesWithPayload(topicParts []string, rulesForDevice []ruleengine.Rule, messagePayload []byte) {
 
 	deviceTopic := topicParts[1]
@@ -92,9 +177,13 @@ func (p *Processor) compareRulesWithPayload(topicParts []string, rulesForDevice
 
 	for _, rule := range rulesForDevice {
 
+		if rule.JsonPathOrEventTag == availabilityRuleTag {
+			continue
+		}
+
 		// EVENT-BASED - suffix monitoring like .../POWER events
 		if deviceSuffix == rule.CompareValue {
-			notifyMonitoredEventArrived(rule.Recipients, fmt.Sprintf("%v %v", rule.MessageRuleActive, string(messagePayload[:])))
+			notifyMonitoredEventArrived(deviceTopic, rule, string(messagePayload[:]), fmt.Sprintf("%v %v", rule.MessageRuleActive, string(messagePayload[:])))
 			continue
 		}
 
@@ -102,7 +191,7 @@ func (p *Processor) compareRulesWithPayload(topicParts []string, rulesForDevice
 		// Get current value of JSON key on JSON path from device payload
 		deviceValue, err := p.jsonParser.GetValueOfJsonKeyOnPath(messagePayload, jsonPathAsArrayElements(rule.JsonPathOrEventTag))
 		if err != nil {
-			slog.Error("Error when processing JSON Path", err, "device", deviceTopic, "json_path", rule.JsonPathOrEventTag, "json_payload", messagePayload)
+			slog.Error("Error when processing JSON Path", "error", err, "device", deviceTopic, "json_path", rule.JsonPathOrEventTag, "json_payload", messagePayload)
 			continue
 		}
 
@@ -166,33 +255,127 @@ func lastJsonPathComponentKeyName(jsonPath string) string {
 	return ""
 }
 
-func notifyMonitoredEventArrived(recipients string, emailBody string) {
-	if len(recipients) > 0 {
-		notificationengine.NotifyChannels(recipients, emailBody)
+func notifyMonitoredEventArrived(device string, rule ruleengine.Rule, eventPayload string, emailBody string) {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	if len(rule.Recipients) > 0 {
+		notifyRuleChannels(device, rule, notificationengine.Notification{State: notificationengine.StateEvent, Value: eventPayload, Message: emailBody})
 	}
 }
 
 func notifyMonitoredValueArrived(device string, deviceValue string, rule ruleengine.Rule) {
-	if len(rule.Recipients) > 0 && !isRuleForThisDeviceAlreadyAlerted(device, rule) {
-		monitoredValueKeyName := lastJsonPathComponentKeyName(rule.JsonPathOrEventTag)
-		// Default email system message (or if no field is specified in rule file)
-		emailBody := fmt.Sprintf("Status of [ %v ] changed. Detected value for key [ %v ] is [ %v ] and monitored condition is [ %v ].", device, monitoredValueKeyName, deviceValue, rule.CompareValue)
-		if len(rule.MessageRuleActive) > 0 && rule.MessageRuleActive != ruleNotificationSytemTag {
-			emailBody = rule.MessageRuleActive
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	// Alerts of rules without recipients are tracked too, they can still run actions
+	if !isRuleForThisDeviceAlreadyAlerted(device, deviceValue, rule) {
+		recordHistoryEvent(HistoryEvent{Device: device, RuleID: rule.ID, Event: historyEventFired, Details: deviceValue})
+		publishHaState(device, rule.ID, true)
+		runRuleActions(device, rule, ruleengine.ActionOnFire)
+		if recordAlertTransition(device, rule) {
+			return
+		}
+		if len(rule.Recipients) > 0 && !notifyRuleChannels(device, rule, notificationengine.Notification{State: notificationengine.StateFiring, Value: deviceValue, Message: alertActiveMessage(device, deviceValue, rule)}) {
+			holdFiringNotification(device, rule)
+		}
+	}
+}
+
+// Send notification to rule channels unless it is muted by silence or inhibited by another alert. Returns true when notification was queued.
+// Device and rule details are filled in to the notification. Must be called with alertsLock held, sending is done later outside of the lock.
+func notifyRuleChannels(device string, rule ruleengine.Rule, notification notificationengine.Notification) bool {
+	if len(rule.Recipients) == 0 {
+		return false
+	}
+	if isSilenced(device, rule.ID) {
+		return false
+	}
+	if inhibitedBy := inhibitingAlert(device, rule); len(inhibitedBy) > 0 {
+		slog.Debug("INHIBIT - Notification suppressed.", "device", device, "rule", rule.ID, "reason", inhibitedBy)
+		return false
+	}
+	notification.Device = device
+	notification.RuleID = rule.ID
+	notification.Condition = rule.CompareValue
+	notification.Severity = rule.Severity
+	if notification.FiredAt.IsZero() {
+		for _, alert := range firedAlertStorage.FiredAlerts[device] {
+			if isAlertForRule(alert, rule) {
+				notification.FiredAt = alert.FiredAt
+				notification.ThreadRoots = maps.Clone(alert.ThreadRoots)
+			}
 		}
-		notificationengine.NotifyChannels(rule.Recipients, emailBody)
 	}
+	queueNotification(rule.Recipients, notification)
+	return true
 }
 
-func isRuleForThisDeviceAlreadyAlerted(device string, rule ruleengine.Rule) bool {
+// Must be called with alertsLock held
+func holdFiringNotification(device string, rule ruleengine.Rule) {
+	for idx, alert := range firedAlertStorage.FiredAlerts[device] {
+		if isAlertForRule(alert, rule) {
+			firedAlertStorage.FiredAlerts[device][idx].FiringHeld = true
+			slog.Debug("ALERT - Firing notification held back.", "device", device, "rule", rule.ID)
+		}
+	}
+}
+
+func releaseHeldFiringNotifications() {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	sendHeldFiringNotifications()
+}
+
+// Send held back firing notifications of alerts which are not silenced or inhibited anymore. Must be called with alertsLock held.
+func sendHeldFiringNotifications() {
+	rules := ruleengine.MonitoringRulesByDevice()
+	for device, storedAlerts := range firedAlertStorage.FiredAlerts {
+		for idx, alert := range storedAlerts {
+			if !alert.FiringHeld || len(alert.AcknowledgedBy) > 0 || isAlertFlapping(device, alert.RuleID) {
+				continue
+			}
+			rule, found := ruleForAlert(rules[device], alert)
+			if !found {
+				continue
+			}
+			emailBody := fmt.Sprintf("%v Alert is active since %v.", alertActiveMessage(device, alert.DeviceValue, rule), alert.FiredAt.Format(time.DateTime))
+			if !notifyRuleChannels(device, rule, notificationengine.Notification{State: notificationengine.StateFiring, Value: alert.DeviceValue, Message: emailBody, FiredAt: alert.FiredAt}) {
+				continue
+			}
+			slog.Info("ALERT - Held back firing notification sent.", "device", device, "rule", rule.ID)
+			storedAlerts[idx].FiringHeld = false
+			storedAlerts[idx].LastNotifiedAt = time.Now()
+		}
+	}
+}
+
+func alertActiveMessage(device string, deviceValue string, rule ruleengine.Rule) string {
+	monitoredValueKeyName := lastJsonPathComponentKeyName(rule.JsonPathOrEventTag)
+	// Default email system message (or if no field is specified in rule file)
+	emailBody := fmt.Sprintf("Status of [ %v ] changed. Detected value for key [ %v ] is [ %v ] and monitored condition is [ %v ].", device, monitoredValueKeyName, deviceValue, rule.CompareValue)
+	if len(rule.MessageRuleActive) > 0 && rule.MessageRuleActive != ruleNotificationSytemTag {
+		emailBody = rule.MessageRuleActive
+	}
+	return emailBody
+}
+
+func isAlertForRule(alert Alert, rule ruleengine.Rule) bool {
+	return alert.AlertJsonPathOrEventTag == rule.JsonPathOrEventTag && alert.AlertMonitoredActionAndValue == rule.CompareValue && alert.Recipients == rule.Recipients
+}
+
+func isRuleForThisDeviceAlreadyAlerted(device string, deviceValue string, rule ruleengine.Rule) bool {
 
 	storedAlerts := firedAlertStorage.FiredAlerts[device]
 	for idx, alert := range storedAlerts {
 		if len(alert.AlertJsonPathOrEventTag) > 0 && len(alert.AlertMonitoredActionAndValue) > 0 {
-			if alert.AlertJsonPathOrEventTag == rule.JsonPathOrEventTag && alert.AlertMonitoredActionAndValue == rule.CompareValue && rule.Recipients == alert.Recipients {
+			if isAlertForRule(alert, rule) {
 
+				alert.DeviceValue = deviceValue
 				if alert.IgnoreCount > 0 {
 					alert.IgnoreCount -= 1
+					if alert.IgnoreCount == 0 {
+						alert.FiredAt = time.Now()
+						alert.LastNotifiedAt = alert.FiredAt
+					}
 					// Update alert
 					firedAlertStorage.FiredAlerts[device] = arrayWithDeletedElementAtIndex(storedAlerts, idx)
 					firedAlertStorage.FiredAlerts[device] = append(firedAlertStorage.FiredAlerts[device], alert)
@@ -204,7 +387,8 @@ func isRuleForThisDeviceAlreadyAlerted(device string, rule ruleengine.Rule) bool
 					slog.Debug("ALERT - Ignore count NOT ZERO yet, ignoring ...", "device", device, "alert", alert)
 					return true
 				} else {
-					// Notified already
+					// Notified already - keep last detected value for reminders
+					storedAlerts[idx] = alert
 					slog.Debug("ALERT - Already notified, ignoring ...", "device", device, "alert", alert)
 					return true
 				}
@@ -213,10 +397,16 @@ func isRuleForThisDeviceAlreadyAlerted(device string, rule ruleengine.Rule) bool
 	}
 
 	newAlert := Alert{}
+	newAlert.RuleID = rule.ID
 	newAlert.IgnoreCount = rule.IgnoreOccurrences
 	newAlert.AlertJsonPathOrEventTag = rule.JsonPathOrEventTag
 	newAlert.AlertMonitoredActionAndValue = rule.CompareValue
 	newAlert.Recipients = rule.Recipients
+	newAlert.DeviceValue = deviceValue
+	if newAlert.IgnoreCount == 0 {
+		newAlert.FiredAt = time.Now()
+		newAlert.LastNotifiedAt = newAlert.FiredAt
+	}
 	firedAlertStorage.FiredAlerts[device] = append(firedAlertStorage.FiredAlerts[device], newAlert)
 
 	if newAlert.IgnoreCount == 0 {
@@ -230,12 +420,33 @@ func isRuleForThisDeviceAlreadyAlerted(device string, rule ruleengine.Rule) bool
 }
 
 func removeAlertIfNotifiedBefore(device string, deviceValue string, rule ruleengine.Rule) {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	resolved := false
 	storedAlerts := firedAlertStorage.FiredAlerts[device]
 	for idx, alert := range storedAlerts {
 		if len(alert.AlertJsonPathOrEventTag) > 0 && len(alert.AlertMonitoredActionAndValue) > 0 {
-			if alert.AlertJsonPathOrEventTag == rule.JsonPathOrEventTag && alert.AlertMonitoredActionAndValue == rule.CompareValue && alert.Recipients == rule.Recipients {
+			if isAlertForRule(alert, rule) {
 				firedAlertStorage.FiredAlerts[device] = arrayWithDeletedElementAtIndex(storedAlerts, idx)
 				slog.Debug("ALERT - Removed.", "device", device, "alert", alert)
+				cancelReceipts(alertReceipts(device, alert))
+
+				// Resolved alert which was not fired yet (ignore count active) is not a transition
+				if !alert.FiredAt.IsZero() {
+					resolved = true
+					recordHistoryEvent(HistoryEvent{Device: device, RuleID: rule.ID, Event: historyEventResolved, Duration: time.Since(alert.FiredAt), Details: deviceValue})
+					publishHaState(device, rule.ID, false)
+					runRuleActions(device, rule, ruleengine.ActionOnResolved)
+					if recordAlertTransition(device, rule) {
+						continue
+					}
+				}
+
+				// Nobody was told that alert fired
+				if alert.FiringHeld {
+					slog.Debug("ALERT - Firing was held back, resolve is not notified.", "device", device, "alert", alert)
+					continue
+				}
 
 				if len(rule.Recipients) > 0 {
 					// Send notification when returned to normal state only when field is specified in rule file
@@ -246,12 +457,92 @@ func removeAlertIfNotifiedBefore(device string, deviceValue string, rule ruleeng
 						if rule.MessageRuleInActive != ruleNotificationSytemTag {
 							emailBody = rule.MessageRuleInActive
 						}
-						notificationengine.NotifyChannels(rule.Recipients, emailBody)
+						if len(alert.AcknowledgedBy) > 0 {
+							emailBody = fmt.Sprintf("%v Alert was acknowledged by %v at %v.", emailBody, alert.AcknowledgedBy, alert.AcknowledgedAt.Format(time.DateTime))
+						}
+						notifyRuleChannels(device, rule, notificationengine.Notification{State: notificationengine.StateResolved, Value: deviceValue, Message: emailBody, FiredAt: alert.FiredAt, ResolvedAt: time.Now(), ThreadRoots: alert.ThreadRoots})
 					}
 				}
 			}
 		}
 	}
+	// Alerts inhibited by resolved alert are notified now
+	if resolved {
+		sendHeldFiringNotifications()
+	}
+}
+
+func (p *Processor) watchActiveAlerts() {
+	ticker := time.NewTicker(activeAlertsCheckInterval)
+	for range ticker.C {
+		removeExpiredSilences()
+		releaseHeldFiringNotifications()
+		p.remindActiveAlerts()
+		p.checkFlappingAlerts()
+		checkSafetyReenable(time.Now())
+		pollChannelReceiptsInBackground()
+		sendScheduledReports(time.Now())
+		storeAlertHistoryPeriodically(time.Now())
+		storeDeviceStatsPeriodically(time.Now())
+	}
+}
+
+// Send reminder for alerts which are still active if rule has repeat interval set
+func (p *Processor) remindActiveAlerts() {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+
+	now := time.Now()
+	rules := ruleengine.MonitoringRulesByDevice()
+	for device, storedAlerts := range firedAlertStorage.FiredAlerts {
+		for idx, alert := range storedAlerts {
+			// Alerts waiting for ignore count to reach zero were not notified yet
+			if alert.FiredAt.IsZero() {
+				continue
+			}
+			// Somebody is already handling the alert
+			if len(alert.AcknowledgedBy) > 0 {
+				continue
+			}
+			// Firing of silenced or inhibited alert was not notified yet
+			if alert.FiringHeld {
+				continue
+			}
+			// Flapping alert was notified already
+			if isAlertFlapping(device, alert.RuleID) {
+				continue
+			}
+			rule, found := ruleForAlert(rules[device], alert)
+			if !found || rule.RepeatInterval <= 0 || len(rule.Recipients) == 0 {
+				continue
+			}
+			if rule.RepeatMax > 0 && alert.RemindersSent >= rule.RepeatMax {
+				continue
+			}
+			if now.Sub(alert.LastNotifiedAt) < rule.RepeatInterval {
+				continue
+			}
+
+			activeFor := now.Sub(alert.FiredAt).Round(time.Second)
+			slog.Debug("ALERT - Sending reminder.", "device", device, "alert", alert, "active_for", activeFor)
+			emailBody := fmt.Sprintf("REMINDER: %v Alert is active for %v.", alertActiveMessage(device, alert.DeviceValue, rule), activeFor)
+			if !notifyRuleChannels(device, rule, notificationengine.Notification{State: notificationengine.StateReminder, Value: alert.DeviceValue, Message: emailBody, FiredAt: alert.FiredAt}) {
+				continue
+			}
+
+			storedAlerts[idx].LastNotifiedAt = now
+			storedAlerts[idx].RemindersSent += 1
+		}
+	}
+}
+
+func ruleForAlert(rulesForDevice []ruleengine.Rule, alert Alert) (ruleengine.Rule, bool) {
+	for _, rule := range rulesForDevice {
+		if isAlertForRule(alert, rule) {
+			return rule, true
+		}
+	}
+	return ruleengine.Rule{}, false
 }
 
 func arrayWithDeletedElementAtIndex(arr []Alert, index int) []Alert {
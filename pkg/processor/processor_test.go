@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+// Load rule lines like from rules/*.conf
+func loadTestRules(t *testing.T, lines ...string) *ruleengine.Rules {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "rules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "rules", "test.conf"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	return ruleengine.NewRules()
+}
+
+func TestNotifyRuleChannelsDetails(t *testing.T) {
+	firedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	rule := ruleengine.Rule{ID: "power", JsonPathOrEventTag: "ENERGY-->Power", CompareValue: ">2000", Recipients: "TEST", Severity: ruleengine.SeverityCritical}
+	tests := []struct {
+		name         string
+		notification notificationengine.Notification
+		wantFiredAt  time.Time
+	}{
+		{name: "fired at of active alert", notification: notificationengine.Notification{State: notificationengine.StateReminder}, wantFiredAt: firedAt},
+		{name: "fired at kept", notification: notificationengine.Notification{State: notificationengine.StateResolved, FiredAt: firedAt.Add(time.Minute)}, wantFiredAt: firedAt.Add(time.Minute)},
+	}
+	for _, tt := range tests {
+		firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{"plug": {{RuleID: rule.ID, AlertJsonPathOrEventTag: rule.JsonPathOrEventTag, AlertMonitoredActionAndValue: rule.CompareValue, Recipients: rule.Recipients, FiredAt: firedAt}}}}
+		notificationQueue = nil
+		if !notifyRuleChannels("plug", rule, tt.notification) {
+			t.Fatalf("%v: notification was not sent", tt.name)
+		}
+		got := notificationQueue[0].notification
+		if got.Device != "plug" || got.RuleID != rule.ID || got.Condition != rule.CompareValue || got.Severity != rule.Severity || got.State != tt.notification.State {
+			t.Errorf("%v: notification = %+v, want device, rule, condition and severity of the rule", tt.name, got)
+		}
+		if !got.FiredAt.Equal(tt.wantFiredAt) {
+			t.Errorf("%v: fired at = %v, want %v", tt.name, got.FiredAt, tt.wantFiredAt)
+		}
+	}
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}}
+	notificationQueue = nil
+}
@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+)
+
+var receiptsPolling atomic.Bool
+
+type channelReceipt struct {
+	device  string
+	ruleID  string
+	channel string
+	receipt string
+}
+
+// Called by notification channel after emergency notification of fired alert was sent.
+// Previous receipt of the same channel (like of reminder) is cancelled, so only the latest notification is repeated.
+func storeChannelReceipt(device string, ruleID string, channel string, receipt string) {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	stored := false
+	var replaced []channelReceipt
+	for idx, alert := range firedAlertStorage.FiredAlerts[device] {
+		if alert.RuleID != ruleID || alert.FiredAt.IsZero() || len(alert.AcknowledgedBy) > 0 {
+			continue
+		}
+		stored = true
+		if alert.Receipts == nil {
+			firedAlertStorage.FiredAlerts[device][idx].Receipts = map[string]string{}
+		}
+		if previous, found := alert.Receipts[channel]; found && previous != receipt {
+			replaced = append(replaced, channelReceipt{device: device, ruleID: ruleID, channel: channel, receipt: previous})
+		}
+		firedAlertStorage.FiredAlerts[device][idx].Receipts[channel] = receipt
+		slog.Debug("ALERT - Receipt stored.", "device", device, "rule", ruleID, "channel", channel, "receipt", receipt)
+	}
+	// Alert was resolved or acknowledged in the meantime
+	if !stored {
+		replaced = append(replaced, channelReceipt{device: device, ruleID: ruleID, channel: channel, receipt: receipt})
+	}
+	cancelReceipts(replaced)
+}
+
+// Called by notification channel after firing notification of alert was sent. Reminders and resolve of the alert are replies to it.
+func storeChannelThreadRoot(device string, ruleID string, channel string, messageId string) {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	for idx, alert := range firedAlertStorage.FiredAlerts[device] {
+		if alert.RuleID != ruleID || alert.FiredAt.IsZero() {
+			continue
+		}
+		if alert.ThreadRoots == nil {
+			firedAlertStorage.FiredAlerts[device][idx].ThreadRoots = map[string]string{}
+		}
+		firedAlertStorage.FiredAlerts[device][idx].ThreadRoots[channel] = messageId
+		slog.Debug("ALERT - Thread root stored.", "device", device, "rule", ruleID, "channel", channel, "message_id", messageId)
+	}
+}
+
+// Status of receipts is read over network, so it does not block watching of alerts. Next poll starts only when previous one is done.
+func pollChannelReceiptsInBackground() {
+	if !receiptsPolling.CompareAndSwap(false, true) {
+		slog.Debug("ALERT - Previous poll of receipts is still running.")
+		return
+	}
+	go func() {
+		defer receiptsPolling.Store(false)
+		pollChannelReceipts()
+	}()
+}
+
+// Alert acknowledged on phone is acknowledged locally too
+func pollChannelReceipts() {
+	for _, r := range pendingReceipts() {
+		if !notificationengine.ChannelExists(r.channel) {
+			slog.Warn("ALERT - Receipt of removed channel dropped.", "device", r.device, "rule", r.ruleID, "channel", r.channel)
+			forgetReceipt(r)
+			continue
+		}
+		if !strings.HasPrefix(r.channel, "PUSHOVER") {
+			continue
+		}
+		acknowledgedBy, pending, err := notificationengine.PushoverReceiptStatus(r.channel, r.receipt)
+		if err != nil {
+			slog.Error("Can not read status of receipt.", "device", r.device, "rule", r.ruleID, "channel", r.channel, "error", err)
+			continue
+		}
+		if pending {
+			continue
+		}
+		forgetReceipt(r)
+		if len(acknowledgedBy) > 0 {
+			if err := AcknowledgeAlert(r.device, r.ruleID, acknowledgedBy); err != nil {
+				slog.Debug("ALERT - Acknowledgement from receipt not applied.", "device", r.device, "rule", r.ruleID, "error", err)
+			}
+		}
+	}
+}
+
+func pendingReceipts() []channelReceipt {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	var receipts []channelReceipt
+	for device, storedAlerts := range firedAlertStorage.FiredAlerts {
+		for _, alert := range storedAlerts {
+			receipts = append(receipts, alertReceipts(device, alert)...)
+		}
+	}
+	return receipts
+}
+
+func alertReceipts(device string, alert Alert) []channelReceipt {
+	var receipts []channelReceipt
+	for channel, receipt := range alert.Receipts {
+		receipts = append(receipts, channelReceipt{device: device, ruleID: alert.RuleID, channel: channel, receipt: receipt})
+	}
+	return receipts
+}
+
+func forgetReceipt(r channelReceipt) {
+	alertsLock.Lock()
+	defer alertsLock.Unlock()
+	for _, alert := range firedAlertStorage.FiredAlerts[r.device] {
+		if alert.RuleID == r.ruleID && alert.Receipts[r.channel] == r.receipt {
+			delete(alert.Receipts, r.channel)
+		}
+	}
+}
+
+// Stop repeating of emergency notifications of resolved or acknowledged alert
+func cancelReceipts(receipts []channelReceipt) {
+	for _, r := range receipts {
+		if strings.HasPrefix(r.channel, "PUSHOVER") {
+			go notificationengine.CancelPushoverReceipt(r.channel, r.receipt)
+		}
+	}
+}
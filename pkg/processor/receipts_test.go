@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+func TestStoreChannelReceipt(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		alert       Alert
+		wantReceipt string
+	}{
+		{name: "fired alert", alert: Alert{RuleID: "power", FiredAt: now}, wantReceipt: "r1"},
+		{name: "previous receipt replaced", alert: Alert{RuleID: "power", FiredAt: now, Receipts: map[string]string{"TEST_PHONE": "r0"}}, wantReceipt: "r1"},
+		{name: "other rule", alert: Alert{RuleID: "offline", FiredAt: now}, wantReceipt: ""},
+		{name: "not fired yet", alert: Alert{RuleID: "power"}, wantReceipt: ""},
+		{name: "acknowledged", alert: Alert{RuleID: "power", FiredAt: now, AcknowledgedBy: "api"}, wantReceipt: ""},
+	}
+	for _, tt := range tests {
+		firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{"plug": {tt.alert}}}
+		// Not a PUSHOVER channel, receipts which can not be stored are not cancelled over network
+		storeChannelReceipt("plug", "power", "TEST_PHONE", "r1")
+		if got := firedAlertStorage.FiredAlerts["plug"][0].Receipts["TEST_PHONE"]; got != tt.wantReceipt {
+			t.Errorf("%v: stored receipt = %q, want %q", tt.name, got, tt.wantReceipt)
+		}
+	}
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}}
+}
+
+func TestPendingAndForgetReceipts(t *testing.T) {
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{
+		"plug": {{RuleID: "power", Receipts: map[string]string{"TEST_A": "r1", "TEST_B": "r2"}}},
+		"lamp": {{RuleID: "offline"}},
+	}}
+	t.Cleanup(func() { firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}} })
+
+	receipts := pendingReceipts()
+	if len(receipts) != 2 {
+		t.Fatalf("pending receipts = %+v, want 2", receipts)
+	}
+	forgetReceipt(channelReceipt{device: "plug", ruleID: "power", channel: "TEST_A", receipt: "r1"})
+	// Receipt replaced by newer notification is kept
+	forgetReceipt(channelReceipt{device: "plug", ruleID: "power", channel: "TEST_B", receipt: "old"})
+	receipts = pendingReceipts()
+	if len(receipts) != 1 || receipts[0] != (channelReceipt{device: "plug", ruleID: "power", channel: "TEST_B", receipt: "r2"}) {
+		t.Errorf("pending receipts after forget = %+v, want receipt r2 of TEST_B", receipts)
+	}
+}
+
+func TestAcknowledgeAlertClearsReceipts(t *testing.T) {
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{
+		"plug": {{RuleID: "power", FiredAt: time.Now(), Receipts: map[string]string{"TEST_PHONE": "r1"}}},
+	}}
+	t.Cleanup(func() { firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}} })
+
+	if err := AcknowledgeAlert("plug", "power", "api"); err != nil {
+		t.Fatal(err)
+	}
+	if receipts := pendingReceipts(); len(receipts) != 0 {
+		t.Errorf("pending receipts after acknowledgement = %+v, want none", receipts)
+	}
+}
+
+func TestThreadRootsOfAlert(t *testing.T) {
+	loadTestRules(t, "0:::plug:::ENERGY-->Power:::>2000:::TEST:::Power is high.:::Power is normal.:::id=power")
+	t.Cleanup(func() { loadTestRules(t) })
+	rule := ruleengine.MonitoringRulesByDevice()["plug"][0]
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}, FlapStates: map[string]FlapState{}}
+	silenceStorage = Silences{}
+	t.Cleanup(func() { firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}} })
+	notificationQueue = nil
+
+	// Firing notification sent after alert was resolved has nowhere to store its thread root
+	storeChannelThreadRoot("plug", "power", "MATRIX_TEST", "$old")
+	notifyMonitoredValueArrived("plug", "2500.000", rule)
+	storeChannelThreadRoot("plug", "power", "MATRIX_TEST", "$event1")
+	storeChannelThreadRoot("plug", "offline", "MATRIX_TEST", "$other")
+	if roots := firedAlertStorage.FiredAlerts["plug"][0].ThreadRoots; len(roots) != 1 || roots["MATRIX_TEST"] != "$event1" {
+		t.Fatalf("thread roots = %v, want $event1 of MATRIX_TEST", roots)
+	}
+	notifyRuleChannels("plug", rule, notificationengine.Notification{State: notificationengine.StateReminder, Message: "Power is still high."})
+	if reminder := notificationQueue[len(notificationQueue)-1].notification; reminder.ThreadRoots["MATRIX_TEST"] != "$event1" {
+		t.Errorf("reminder %+v is not reply to $event1", reminder)
+	}
+
+	if err := AcknowledgeAlert("plug", "power", "api"); err != nil {
+		t.Fatal(err)
+	}
+	removeAlertIfNotifiedBefore("plug", "100.000", rule)
+	if len(firedAlertStorage.FiredAlerts["plug"]) != 0 {
+		t.Errorf("resolved alert is still stored")
+	}
+	if len(notificationQueue) != 3 {
+		t.Fatalf("queued notifications = %+v, want firing, reminder and resolved", notificationQueue)
+	}
+	if resolved := notificationQueue[2].notification; resolved.State != notificationengine.StateResolved || resolved.ThreadRoots["MATRIX_TEST"] != "$event1" {
+		t.Errorf("resolved notification %+v is not reply to $event1", resolved)
+	}
+}
+
+func TestPollChannelReceiptsDropsRemovedChannel(t *testing.T) {
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{
+		"plug": {{RuleID: "power", FiredAt: time.Now(), Receipts: map[string]string{"PUSHOVER_REMOVED": "r1"}}},
+	}}
+	t.Cleanup(func() { firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}} })
+
+	// Status of receipt of channel which is not configured is not requested over network
+	pollChannelReceipts()
+	if receipts := pendingReceipts(); len(receipts) != 0 {
+		t.Errorf("pending receipts = %+v, want none", receipts)
+	}
+}
@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemindActiveAlerts(t *testing.T) {
+	now := time.Now()
+	ruleLine := "0:::plug-freezer:::ENERGY-->Power:::<5:::TEST:::Freezer is not running!::::::repeat=1h:::repeat_max=2"
+	firedAlert := Alert{AlertJsonPathOrEventTag: "ENERGY-->Power", AlertMonitoredActionAndValue: "<5", Recipients: "TEST",
+		DeviceValue: "0", FiredAt: now.Add(-3 * time.Hour), LastNotifiedAt: now.Add(-2 * time.Hour)}
+
+	tests := []struct {
+		name          string
+		ruleLine      string
+		alert         Alert
+		flapping      bool
+		wantReminders int64
+	}{
+		{name: "reminder is due", ruleLine: ruleLine, alert: firedAlert, wantReminders: 1},
+		{name: "not fired yet", ruleLine: ruleLine, alert: func() Alert { a := firedAlert; a.FiredAt = time.Time{}; return a }()},
+		{name: "repeat interval not elapsed", ruleLine: ruleLine, alert: func() Alert { a := firedAlert; a.LastNotifiedAt = now.Add(-time.Minute); return a }()},
+		{name: "maximum of reminders sent", ruleLine: ruleLine, alert: func() Alert { a := firedAlert; a.RemindersSent = 2; return a }(), wantReminders: 2},
+		{name: "acknowledged alert", ruleLine: ruleLine, alert: func() Alert { a := firedAlert; a.AcknowledgedBy = "cli"; return a }()},
+		{name: "flapping alert", ruleLine: ruleLine, alert: firedAlert, flapping: true},
+		{name: "rule without repeat", ruleLine: "0:::plug-freezer:::ENERGY-->Power:::<5:::TEST:::Freezer is not running!", alert: firedAlert},
+		{name: "unlimited reminders", ruleLine: "0:::plug-freezer:::ENERGY-->Power:::<5:::TEST:::Freezer is not running!::::::repeat=1h",
+			alert: func() Alert { a := firedAlert; a.RemindersSent = 10; return a }(), wantReminders: 11},
+		{name: "rule of other device", ruleLine: "0:::plug-fridge:::ENERGY-->Power:::<5:::TEST:::Freezer is not running!::::::repeat=1h", alert: firedAlert},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Processor{}
+			loadTestRules(t, tt.ruleLine)
+			t.Cleanup(func() { loadTestRules(t) })
+			firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{"plug-freezer": {tt.alert}}, FlapStates: map[string]FlapState{}}
+			if tt.flapping {
+				firedAlertStorage.FlapStates[flapKey("plug-freezer", tt.alert.RuleID)] = FlapState{Flapping: true}
+			}
+			notificationQueue = nil
+
+			p.remindActiveAlerts()
+
+			got := firedAlertStorage.FiredAlerts["plug-freezer"][0]
+			if got.RemindersSent != tt.wantReminders {
+				t.Errorf("RemindersSent = %v, want %v", got.RemindersSent, tt.wantReminders)
+			}
+			reminded := got.RemindersSent > tt.alert.RemindersSent
+			if reminded != (len(notificationQueue) == 1) {
+				t.Fatalf("queued %v notifications, reminder sent %v", len(notificationQueue), reminded)
+			}
+			if !reminded {
+				return
+			}
+			if message := notificationQueue[0].notification.Message; !strings.HasPrefix(message, "REMINDER:") || !strings.Contains(message, "active for 3h0m0s") {
+				t.Errorf("unexpected reminder %q", message)
+			}
+			if !got.LastNotifiedAt.After(tt.alert.LastNotifiedAt) {
+				t.Errorf("LastNotifiedAt was not updated")
+			}
+		})
+	}
+}
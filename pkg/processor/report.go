@@ -0,0 +1,304 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+const (
+	dayFormat          = "2006-01-02"
+	deviceStatsStorage = "storage/deviceStats.json"
+	// Energy values are stored also while running, so reports are complete after restart
+	deviceStatsStoreInterval = time.Hour
+)
+
+// Energy values reported by device in ENERGY part of SENSOR (or STATUS0) payload
+type deviceEnergy struct {
+	Today     float64
+	Yesterday float64
+	// Energy consumed per day
+	Energy map[string]float64
+	// Peak power per day
+	PeakPower map[string]float64
+}
+
+type energyPayload struct {
+	Today     *float64 `json:"Today"`
+	Yesterday *float64 `json:"Yesterday"`
+	// Number or array of numbers for multi-channel devices
+	Power any `json:"Power"`
+}
+
+type sensorPayload struct {
+	ENERGY    *energyPayload `json:"ENERGY"`
+	StatusSNS *struct {
+		ENERGY *energyPayload `json:"ENERGY"`
+	} `json:"StatusSNS"`
+}
+
+var (
+	deviceStats = map[string]*deviceEnergy{}
+	statsLock   sync.Mutex
+	// Stats have values not written to storage yet
+	statsChanged  bool
+	statsStoredAt = time.Now()
+	// Last time report of schedule was sent, reports are not sent for schedules missed before start
+	reportsLastRun     = map[string]time.Time{}
+	processorStartedAt = time.Now()
+)
+
+func recordEnergyStats(device string, messagePayload []byte) {
+	if !bytes.Contains(messagePayload, []byte(`"ENERGY"`)) {
+		return
+	}
+	var payload sensorPayload
+	if err := json.Unmarshal(messagePayload, &payload); err != nil {
+		slog.Debug("Can not read energy values from payload.", "device", device, "error", err)
+		return
+	}
+	energy := payload.ENERGY
+	if energy == nil && payload.StatusSNS != nil {
+		energy = payload.StatusSNS.ENERGY
+	}
+	if energy == nil {
+		return
+	}
+
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	statsChanged = true
+	stats := deviceStats[device]
+	if stats == nil {
+		stats = &deviceEnergy{Energy: map[string]float64{}, PeakPower: map[string]float64{}}
+		deviceStats[device] = stats
+	}
+	now := time.Now()
+	day := now.Format(dayFormat)
+	if energy.Today != nil {
+		stats.Today = *energy.Today
+		stats.Energy[day] = *energy.Today
+	}
+	// Device counter of yesterday is final value for the whole day
+	if energy.Yesterday != nil {
+		stats.Yesterday = *energy.Yesterday
+		stats.Energy[now.AddDate(0, 0, -1).Format(dayFormat)] = *energy.Yesterday
+	}
+	if power := sumOfPower(energy.Power); power > stats.PeakPower[day] {
+		stats.PeakPower[day] = power
+	}
+	// Keep values for weekly report only
+	for _, perDay := range []map[string]float64{stats.Energy, stats.PeakPower} {
+		for d := range perDay {
+			if t, err := time.Parse(dayFormat, d); err == nil && time.Since(t) > 8*24*time.Hour {
+				delete(perDay, d)
+			}
+		}
+	}
+}
+
+func storeDeviceStatsPeriodically(now time.Time) {
+	statsLock.Lock()
+	due := statsChanged && now.Sub(statsStoredAt) >= deviceStatsStoreInterval
+	statsLock.Unlock()
+	if due {
+		StoreDeviceStats()
+	}
+}
+
+func StoreDeviceStats() {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+
+	statsChanged = false
+	statsStoredAt = time.Now()
+
+	slog.Info("Storing device energy stats.", "file", deviceStatsStorage)
+	jsonData, err := json.Marshal(deviceStats)
+	if err != nil {
+		slog.Error("Error encoding JSON when storing device energy stats.", "error", err)
+		return
+	}
+	if err := os.WriteFile(deviceStatsStorage, jsonData, 0644); err != nil {
+		slog.Error("Error writing device energy stats to file.", "file", deviceStatsStorage, "error", err)
+	}
+}
+
+func readDeviceStats() map[string]*deviceEnergy {
+	jsonData, err := os.ReadFile(deviceStatsStorage)
+	if err != nil {
+		slog.Debug("File with device energy stats does not exist or is not readable.")
+		return map[string]*deviceEnergy{}
+	}
+	restoredData := map[string]*deviceEnergy{}
+	if err := json.Unmarshal(jsonData, &restoredData); err != nil {
+		slog.Error("Error decoding JSON from device energy stats.", "error", err)
+		return map[string]*deviceEnergy{}
+	}
+	for device, stats := range restoredData {
+		if stats == nil {
+			delete(restoredData, device)
+			continue
+		}
+		if stats.Energy == nil {
+			stats.Energy = map[string]float64{}
+		}
+		if stats.PeakPower == nil {
+			stats.PeakPower = map[string]float64{}
+		}
+	}
+	slog.Info("Device energy stats loaded.", "file", deviceStatsStorage, "devices", len(restoredData))
+	return restoredData
+}
+
+// Energy of whole days from the day of from until the day before to
+func (stats *deviceEnergy) energyTotal(from time.Time, to time.Time) float64 {
+	total := 0.0
+	lastDay := to.Format(dayFormat)
+	for day := from; day.Format(dayFormat) < lastDay; day = day.AddDate(0, 0, 1) {
+		total += stats.Energy[day.Format(dayFormat)]
+	}
+	return total
+}
+
+func sumOfPower(power any) float64 {
+	switch value := power.(type) {
+	case float64:
+		return value
+	case []any:
+		sum := 0.0
+		for _, channel := range value {
+			if channelPower, ok := channel.(float64); ok {
+				sum += channelPower
+			}
+		}
+		return sum
+	}
+	return 0
+}
+
+func sendScheduledReports(now time.Time) {
+	for _, schedule := range ruleengine.ReportSchedules() {
+		scheduledAt := lastScheduledTime(schedule, now)
+		key := fmt.Sprintf("%v/%v/%02d:%02d/%v", schedule.Weekly, schedule.Weekday, schedule.Hour, schedule.Minute, schedule.Recipients)
+		lastRun, found := reportsLastRun[key]
+		if !found {
+			lastRun = processorStartedAt
+		}
+		// Late reports (like after suspend) are sent only within one hour
+		if !lastRun.Before(scheduledAt) || now.Sub(scheduledAt) > time.Hour {
+			continue
+		}
+		reportsLastRun[key] = now
+
+		title := "Tasmota alerter daily report"
+		period := 24 * time.Hour
+		if schedule.Weekly {
+			title = "Tasmota alerter weekly report"
+			period = 7 * 24 * time.Hour
+		}
+		slog.Info("Sending scheduled report.", "title", title, "recipients", schedule.Recipients)
+		report := buildReport(title, scheduledAt.Add(-period), now)
+		notificationengine.NotifyChannels(schedule.Recipients, notificationengine.Notification{State: notificationengine.StateReport, Message: report, Time: now})
+		StoreAlertHistory()
+	}
+}
+
+// Last time at or before now when the report should be sent
+func lastScheduledTime(schedule ruleengine.ReportSchedule, now time.Time) time.Time {
+	scheduledAt := time.Date(now.Year(), now.Month(), now.Day(), schedule.Hour, schedule.Minute, 0, 0, now.Location())
+	if scheduledAt.After(now) {
+		scheduledAt = scheduledAt.AddDate(0, 0, -1)
+	}
+	for schedule.Weekly && scheduledAt.Weekday() != schedule.Weekday {
+		scheduledAt = scheduledAt.AddDate(0, 0, -1)
+	}
+	return scheduledAt
+}
+
+func buildReport(title string, from time.Time, to time.Time) string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "%v (%v - %v)\n", title, from.Format("2006-01-02 15:04"), to.Format("2006-01-02 15:04"))
+
+	report.WriteString("\nEnergy:\n")
+	statsLock.Lock()
+	devices := make([]string, 0, len(deviceStats))
+	for device := range deviceStats {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	for _, device := range devices {
+		stats := deviceStats[device]
+		peakPower := 0.0
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			peakPower = max(peakPower, stats.PeakPower[day.Format(dayFormat)])
+		}
+		fmt.Fprintf(&report, "[ %v ] today %.3f kWh, yesterday %.3f kWh, period total %.3f kWh, peak power %.1f W\n", device, stats.Today, stats.Yesterday, stats.energyTotal(from, to), peakPower)
+	}
+	statsLock.Unlock()
+	if len(devices) == 0 {
+		report.WriteString("No energy data.\n")
+	}
+
+	var alertLines, offlineLines []string
+	fired, resolved := 0, 0
+	for _, event := range historyEventsSince(from) {
+		at := event.Time.Format("2006-01-02 15:04:05")
+		switch event.Event {
+		case historyEventFired:
+			fired++
+			alertLines = append(alertLines, fmt.Sprintf("%v [ %v ] %v fired", at, event.Device, event.RuleID))
+		case historyEventResolved:
+			resolved++
+			alertLines = append(alertLines, fmt.Sprintf("%v [ %v ] %v resolved after %v", at, event.Device, event.RuleID, event.Duration.Round(time.Second)))
+		case historyEventOffline:
+			offlineLines = append(offlineLines, fmt.Sprintf("%v [ %v ]", at, event.Device))
+		default:
+			alertLines = append(alertLines, fmt.Sprintf("%v [ %v ] %v %v %v", at, event.Device, event.RuleID, event.Event, event.Details))
+		}
+	}
+	fmt.Fprintf(&report, "\nAlerts fired: %v, resolved: %v\n", fired, resolved)
+	for _, line := range alertLines {
+		fmt.Fprintf(&report, "- %v\n", line)
+	}
+
+	report.WriteString("\nDevices offline:\n")
+	for _, line := range offlineLines {
+		fmt.Fprintf(&report, "- %v\n", line)
+	}
+	if len(offlineLines) == 0 {
+		report.WriteString("None.\n")
+	}
+
+	report.WriteString("\nActive alerts:\n")
+	activeAlerts := 0
+	alertsLock.Lock()
+	for device, storedAlerts := range firedAlertStorage.FiredAlerts {
+		for _, alert := range storedAlerts {
+			if alert.FiredAt.IsZero() {
+				continue
+			}
+			activeAlerts++
+			fmt.Fprintf(&report, "- [ %v ] %v active for %v", device, alert.RuleID, to.Sub(alert.FiredAt).Round(time.Second))
+			if len(alert.AcknowledgedBy) > 0 {
+				fmt.Fprintf(&report, ", acknowledged by %v", alert.AcknowledgedBy)
+			}
+			report.WriteString("\n")
+		}
+	}
+	alertsLock.Unlock()
+	if activeAlerts == 0 {
+		report.WriteString("None.\n")
+	}
+
+	return strings.TrimSpace(report.String())
+}
@@ -0,0 +1,166 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+func TestLastScheduledTime(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, 5, 8, 10, 30, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		schedule ruleengine.ReportSchedule
+		want     time.Time
+	}{
+		{name: "daily earlier today", schedule: ruleengine.ReportSchedule{Hour: 7}, want: time.Date(2024, 5, 8, 7, 0, 0, 0, time.Local)},
+		{name: "daily exactly now", schedule: ruleengine.ReportSchedule{Hour: 10, Minute: 30}, want: now},
+		{name: "daily later today", schedule: ruleengine.ReportSchedule{Hour: 20}, want: time.Date(2024, 5, 7, 20, 0, 0, 0, time.Local)},
+		{name: "weekly today", schedule: ruleengine.ReportSchedule{Weekly: true, Weekday: time.Wednesday, Hour: 7}, want: time.Date(2024, 5, 8, 7, 0, 0, 0, time.Local)},
+		{name: "weekly later today", schedule: ruleengine.ReportSchedule{Weekly: true, Weekday: time.Wednesday, Hour: 20}, want: time.Date(2024, 5, 1, 20, 0, 0, 0, time.Local)},
+		{name: "weekly earlier this week", schedule: ruleengine.ReportSchedule{Weekly: true, Weekday: time.Monday, Hour: 7}, want: time.Date(2024, 5, 6, 7, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		if got := lastScheduledTime(tt.schedule, now); !got.Equal(tt.want) {
+			t.Errorf("%v: lastScheduledTime() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSumOfPower(t *testing.T) {
+	tests := []struct {
+		power any
+		want  float64
+	}{
+		{power: 120.0, want: 120},
+		{power: []any{10.0, 20.0, 30.0}, want: 60},
+		{power: []any{10.0, "bad"}, want: 10},
+		{power: "bad", want: 0},
+		{power: nil, want: 0},
+	}
+	for _, tt := range tests {
+		if got := sumOfPower(tt.power); got != tt.want {
+			t.Errorf("sumOfPower(%v) = %v, want %v", tt.power, got, tt.want)
+		}
+	}
+}
+
+func TestRecordEnergyStats(t *testing.T) {
+	t.Cleanup(func() { deviceStats = map[string]*deviceEnergy{} })
+	today := time.Now().Format(dayFormat)
+	tests := []struct {
+		name      string
+		payload   string
+		wantToday float64
+		wantPeak  float64
+	}{
+		{name: "no energy", payload: `{"Temperature":20}`, wantToday: 0, wantPeak: 0},
+		{name: "sensor", payload: `{"ENERGY":{"Today":1.5,"Yesterday":3.2,"Power":100}}`, wantToday: 1.5, wantPeak: 100},
+		{name: "lower power keeps peak", payload: `{"ENERGY":{"Today":1.6,"Power":50}}`, wantToday: 1.6, wantPeak: 100},
+		{name: "status", payload: `{"StatusSNS":{"ENERGY":{"Today":1.7,"Power":[80,70]}}}`, wantToday: 1.7, wantPeak: 150},
+		{name: "invalid json", payload: `{"ENERGY":`, wantToday: 1.7, wantPeak: 150},
+	}
+	for _, tt := range tests {
+		recordEnergyStats("plug", []byte(tt.payload))
+		stats := deviceStats["plug"]
+		var gotToday, gotPeak float64
+		if stats != nil {
+			gotToday = stats.Today
+			gotPeak = stats.PeakPower[today]
+		}
+		if gotToday != tt.wantToday || gotPeak != tt.wantPeak {
+			t.Errorf("%v: today = %v, peak = %v, want %v, %v", tt.name, gotToday, gotPeak, tt.wantToday, tt.wantPeak)
+		}
+	}
+	stats := deviceStats["plug"]
+	if stats.Yesterday != 3.2 {
+		t.Errorf("yesterday = %v, want 3.2", stats.Yesterday)
+	}
+	yesterday := time.Now().AddDate(0, 0, -1).Format(dayFormat)
+	if stats.Energy[today] != 1.7 || stats.Energy[yesterday] != 3.2 {
+		t.Errorf("energy per day = %v, want 1.7 today and 3.2 yesterday", stats.Energy)
+	}
+}
+
+func TestEnergyTotal(t *testing.T) {
+	stats := deviceEnergy{Energy: map[string]float64{
+		"2024-04-28": 9.0,
+		"2024-04-29": 1.0,
+		"2024-04-30": 2.0,
+		"2024-05-01": 3.0,
+		"2024-05-05": 4.0,
+		"2024-05-06": 5.0,
+	}}
+	monday := time.Date(2024, 5, 6, 8, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		from time.Time
+		want float64
+	}{
+		{name: "daily report counts yesterday", from: monday.AddDate(0, 0, -1), want: 4.0},
+		{name: "weekly report counts seven whole days", from: monday.AddDate(0, 0, -7), want: 10.0},
+		{name: "empty period", from: monday, want: 0},
+	}
+	for _, tt := range tests {
+		if got := stats.energyTotal(tt.from, monday); got != tt.want {
+			t.Errorf("%v: energyTotal() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStoreAndReadDeviceStats(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "storage"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Cleanup(func() { deviceStats = map[string]*deviceEnergy{} })
+
+	if stats := readDeviceStats(); len(stats) != 0 {
+		t.Errorf("stats read without storage file = %v, want none", stats)
+	}
+	deviceStats = map[string]*deviceEnergy{}
+	statsChanged = false
+	recordEnergyStats("plug-fridge", []byte(`{"ENERGY":{"Today":1.5,"Yesterday":2.25,"Power":80}}`))
+	now := time.Now()
+	// Stats are stored at most once per interval
+	statsStoredAt = now
+	storeDeviceStatsPeriodically(now.Add(time.Minute))
+	if _, err := os.Stat(deviceStatsStorage); err == nil {
+		t.Fatalf("stats were stored before interval elapsed")
+	}
+	storeDeviceStatsPeriodically(now.Add(deviceStatsStoreInterval))
+	if statsChanged {
+		t.Errorf("stats are still changed after they were stored")
+	}
+
+	deviceStats = map[string]*deviceEnergy{}
+	restored := readDeviceStats()
+	stats := restored["plug-fridge"]
+	if stats == nil {
+		t.Fatalf("stats of device were not restored: %v", restored)
+	}
+	day := now.Format(dayFormat)
+	if stats.Today != 1.5 || stats.Yesterday != 2.25 || stats.Energy[day] != 1.5 || stats.PeakPower[day] != 80 {
+		t.Errorf("restored stats = %+v", stats)
+	}
+
+	if err := os.WriteFile(deviceStatsStorage, []byte(`{"plug-lamp":{"Today":0.5}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Days are recorded also to stats stored without them
+	restored = readDeviceStats()
+	restored["plug-lamp"].Energy[day] = 0.5
+	restored["plug-lamp"].PeakPower[day] = 10
+}
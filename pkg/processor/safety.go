@@ -0,0 +1,238 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+const (
+	safetyStatesStorage     = "storage/safetyStates.json"
+	historyEventShutoff     = "shutoff"
+	historyEventReenabled   = "re-enabled"
+	historyEventSafetyReset = "safety reset"
+)
+
+type SafetyState struct {
+	Device string
+	RuleID string
+	// Since when the condition is met continuously
+	ExceededSince time.Time
+	// Count of shutoffs since last manual reset
+	Shutoffs      int
+	LastShutoffAt time.Time
+	// Device is switched on again at this time (after cooldown)
+	ReenableAt time.Time
+	// No more automatic re-enable until manual reset
+	LockedOut bool
+}
+
+type SafetyStates struct {
+	States map[string]*SafetyState
+}
+
+var (
+	safetyStorage SafetyStates
+	safetyLock    sync.Mutex
+)
+
+// Must be called with safetyLock held
+func safetyState(device string, ruleID string) *SafetyState {
+	key := device + "/" + ruleID
+	state := safetyStorage.States[key]
+	if state == nil {
+		state = &SafetyState{Device: device, RuleID: ruleID}
+		safetyStorage.States[key] = state
+	}
+	return state
+}
+
+func (p *Processor) checkSafetyRules(device string, messagePayload []byte) {
+	for _, rule := range ruleengine.SafetyRulesForDevice(device) {
+		deviceValue, err := p.jsonParser.GetValueOfJsonKeyOnPath(messagePayload, jsonPathAsArrayElements(rule.JsonPath))
+		if err != nil || deviceValue == nil {
+			continue
+		}
+		met, ok := isConditionMet(rule.CompareValue, deviceValue)
+		if !ok {
+			continue
+		}
+
+		now := time.Now()
+		safetyLock.Lock()
+		state := safetyState(device, rule.ID)
+		if !met {
+			state.ExceededSince = time.Time{}
+			safetyLock.Unlock()
+			continue
+		}
+		if state.ExceededSince.IsZero() {
+			state.ExceededSince = now
+		}
+		if now.Sub(state.ExceededSince) < rule.For {
+			safetyLock.Unlock()
+			continue
+		}
+
+		state.ExceededSince = time.Time{}
+		state.Shutoffs++
+		state.LastShutoffAt = now
+		state.ReenableAt = time.Time{}
+		if rule.Cooldown > 0 {
+			if rule.Retries != ruleengine.UnlimitedRetries && state.Shutoffs > rule.Retries {
+				state.LockedOut = true
+			} else {
+				state.ReenableAt = now.Add(rule.Cooldown)
+			}
+		}
+		stateSnapshot := *state
+		safetyStorage.store()
+		safetyLock.Unlock()
+
+		go shutoffDevice(device, rule, stateSnapshot, fmt.Sprintf("%v", deviceValue))
+	}
+}
+
+func shutoffDevice(device string, rule ruleengine.SafetyRule, state SafetyState, deviceValue string) {
+	slog.Warn("SAFETY - Switching device off.", "device", device, "rule", rule.ID, "value", deviceValue, "condition", rule.CompareValue)
+	result := sendCommandAndWaitForResult("cmnd/"+device+"/POWER", "OFF")
+
+	emailBody := fmt.Sprintf("SAFETY SHUTOFF of [ %v ]. Value of [ %v ] is [ %v ] and met condition [ %v ] for %v. Power OFF: %v.", device, lastJsonPathComponentKeyName(rule.JsonPath), deviceValue, rule.CompareValue, rule.For, result)
+	switch {
+	case state.LockedOut:
+		emailBody += fmt.Sprintf(" Device is LOCKED OUT after %v shutoffs and needs manual reset (tasmota-alerter safety reset %v %v).", state.Shutoffs, device, rule.ID)
+	case !state.ReenableAt.IsZero() && rule.Retries == ruleengine.UnlimitedRetries:
+		emailBody += fmt.Sprintf(" Power will be switched on at %v (retry %v).", state.ReenableAt.Format(time.TimeOnly), state.Shutoffs)
+	case !state.ReenableAt.IsZero():
+		emailBody += fmt.Sprintf(" Power will be switched on at %v (retry %v of %v).", state.ReenableAt.Format(time.TimeOnly), state.Shutoffs, rule.Retries)
+	}
+	recordHistoryEvent(HistoryEvent{Device: device, RuleID: rule.ID, Event: historyEventShutoff, Details: fmt.Sprintf("value %v, power off %v, locked out %v", deviceValue, result, state.LockedOut)})
+	notifySafetyChannels(device, rule, emailBody)
+}
+
+// Switch devices on again after cooldown
+func checkSafetyReenable(now time.Time) {
+	safetyLock.Lock()
+	var reenable []SafetyState
+	for _, state := range safetyStorage.States {
+		if !state.LockedOut && !state.ReenableAt.IsZero() && !now.Before(state.ReenableAt) {
+			state.ReenableAt = time.Time{}
+			reenable = append(reenable, *state)
+		}
+	}
+	if len(reenable) > 0 {
+		safetyStorage.store()
+	}
+	safetyLock.Unlock()
+
+	for _, state := range reenable {
+		go reenableDevice(state)
+	}
+}
+
+func reenableDevice(state SafetyState) {
+	slog.Info("SAFETY - Switching device on after cooldown.", "device", state.Device, "rule", state.RuleID)
+	result := sendCommandAndWaitForResult("cmnd/"+state.Device+"/POWER", "ON")
+	recordHistoryEvent(HistoryEvent{Device: state.Device, RuleID: state.RuleID, Event: historyEventReenabled, Details: result})
+	if rule, found := ruleengine.SafetyRuleByID(state.Device, state.RuleID); found {
+		notifySafetyChannels(state.Device, rule, fmt.Sprintf("SAFETY cooldown of [ %v ] is over. Power ON: %v.", state.Device, result))
+	}
+}
+
+// Safety notifications are not muted by silences
+func notifySafetyChannels(device string, rule ruleengine.SafetyRule, message string) {
+	if len(rule.Recipients) > 0 {
+		notificationengine.NotifyChannels(rule.Recipients, notificationengine.Notification{Device: device, RuleID: rule.ID, State: notificationengine.StateSafety, Severity: ruleengine.SeverityCritical, Condition: rule.CompareValue, Message: message})
+	}
+}
+
+// Manual reset clears lockout and count of shutoffs. Device is not switched on automatically.
+func ResetSafetyLockout(device string, ruleID string, user string) error {
+	if _, found := ruleengine.SafetyRuleByID(device, ruleID); !found {
+		return fmt.Errorf("no safety rule %q found for device %q", ruleID, device)
+	}
+	safetyLock.Lock()
+	state := safetyState(device, ruleID)
+	state.Shutoffs = 0
+	state.LockedOut = false
+	state.ReenableAt = time.Time{}
+	safetyStorage.store()
+	safetyLock.Unlock()
+
+	slog.Info("SAFETY - Reset.", "device", device, "rule", ruleID, "user", user)
+	recordHistoryEvent(HistoryEvent{Device: device, RuleID: ruleID, Event: historyEventSafetyReset, Details: "by " + user})
+	return nil
+}
+
+func ListSafetyStates() []SafetyState {
+	safetyLock.Lock()
+	defer safetyLock.Unlock()
+	var states []SafetyState
+	for _, state := range safetyStorage.States {
+		states = append(states, *state)
+	}
+	return states
+}
+
+// Compare device value with condition like >2000 or =ON. Returns false as second value when it can't be compared.
+func isConditionMet(compareValue string, deviceValue any) (bool, bool) {
+	if len(compareValue) < 2 {
+		return false, false
+	}
+	comparison := compareValue[:1]
+	ruleValue := compareValue[1:]
+	ruleNumber, err := strconv.ParseFloat(ruleValue, 64)
+	if err != nil {
+		value, ok := deviceValue.(string)
+		return ok && value == ruleValue, ok
+	}
+	value, ok := deviceValue.(float64)
+	if !ok {
+		return false, false
+	}
+	switch comparison {
+	case "=":
+		return value == ruleNumber, true
+	case ">":
+		return value > ruleNumber, true
+	case "<":
+		return value < ruleNumber, true
+	}
+	return false, false
+}
+
+func (states SafetyStates) store() {
+	jsonData, err := json.Marshal(states)
+	if err != nil {
+		slog.Error("Error encoding JSON when storing safety states.", "error", err)
+		return
+	}
+	if err := os.WriteFile(safetyStatesStorage, jsonData, 0644); err != nil {
+		slog.Error("Error writing safety states to file.", "file", safetyStatesStorage, "error", err)
+	}
+}
+
+func readSafetyStates() SafetyStates {
+	restoredData := SafetyStates{States: map[string]*SafetyState{}}
+	jsonData, err := os.ReadFile(safetyStatesStorage)
+	if err != nil {
+		slog.Debug("File with safety states does not exist or is not readable.")
+		return restoredData
+	}
+	if err := json.Unmarshal(jsonData, &restoredData); err != nil {
+		slog.Error("Error decoding JSON from safety states.", "error", err)
+		return SafetyStates{States: map[string]*SafetyState{}}
+	}
+	if restoredData.States == nil {
+		restoredData.States = map[string]*SafetyState{}
+	}
+	slog.Info("Safety states loaded.", "file", safetyStatesStorage, "count", len(restoredData.States))
+	return restoredData
+}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/jorycz/sp-json"
+)
+
+func TestIsConditionMet(t *testing.T) {
+	tests := []struct {
+		compareValue string
+		deviceValue  any
+		wantMet      bool
+		wantOk       bool
+	}{
+		{compareValue: ">2000", deviceValue: 2500.0, wantMet: true, wantOk: true},
+		{compareValue: ">2000", deviceValue: 2000.0, wantMet: false, wantOk: true},
+		{compareValue: "<10", deviceValue: 5.5, wantMet: true, wantOk: true},
+		{compareValue: "=0", deviceValue: 0.0, wantMet: true, wantOk: true},
+		{compareValue: "=ON", deviceValue: "ON", wantMet: true, wantOk: true},
+		{compareValue: "=ON", deviceValue: "OFF", wantMet: false, wantOk: true},
+		{compareValue: ">2000", deviceValue: "high", wantMet: false, wantOk: false},
+		{compareValue: "=ON", deviceValue: 1.0, wantMet: false, wantOk: false},
+		{compareValue: ">", deviceValue: 1.0, wantMet: false, wantOk: false},
+	}
+	for _, tt := range tests {
+		met, ok := isConditionMet(tt.compareValue, tt.deviceValue)
+		if met != tt.wantMet || ok != tt.wantOk {
+			t.Errorf("isConditionMet(%q, %v) = %v, %v, want %v, %v", tt.compareValue, tt.deviceValue, met, ok, tt.wantMet, tt.wantOk)
+		}
+	}
+}
+
+func TestSafetyShutoffRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       string
+		shutoffs      int
+		wantLockedOut bool
+	}{
+		{name: "no cooldown", options: "", shutoffs: 1, wantLockedOut: false},
+		{name: "retries left", options: ":::cooldown=10m:::retries=2", shutoffs: 2, wantLockedOut: false},
+		{name: "retries used", options: ":::cooldown=10m:::retries=2", shutoffs: 3, wantLockedOut: true},
+		{name: "zero retries", options: ":::cooldown=10m:::retries=0", shutoffs: 1, wantLockedOut: true},
+		{name: "unlimited retries", options: ":::cooldown=10m", shutoffs: 10, wantLockedOut: false},
+	}
+	p := &Processor{jsonParser: &parser.JSONParser{}}
+	for _, tt := range tests {
+		loadTestRules(t, "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::0s::::::id=heater"+tt.options)
+		safetyStorage = SafetyStates{States: map[string]*SafetyState{}}
+		for i := 0; i < tt.shutoffs; i++ {
+			p.checkSafetyRules("plug-heater", []byte(`{"ENERGY":{"Power":2500}}`))
+		}
+		state := ListSafetyStates()
+		if len(state) != 1 || state[0].Shutoffs != tt.shutoffs || state[0].LockedOut != tt.wantLockedOut {
+			t.Errorf("%v: safety states = %+v, want %v shutoffs and locked out %v", tt.name, state, tt.shutoffs, tt.wantLockedOut)
+		}
+	}
+	loadTestRules(t)
+	safetyStorage = SafetyStates{States: map[string]*SafetyState{}}
+}
@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const silencesStorage = "storage/silences.json"
+
+// Silence mutes notifications for matching device (name or glob like plug-*) and/or rule ID between StartsAt and EndsAt.
+// Alerts are still tracked while silenced, firing notification of alert which is still active is sent when silence ends.
+type Silence struct {
+	ID        string    `json:"id"`
+	Device    string    `json:"device,omitempty"`
+	RuleID    string    `json:"rule,omitempty"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+}
+
+type Silences struct {
+	Silences []Silence
+}
+
+var (
+	silenceStorage Silences
+	silencesLock   sync.Mutex
+)
+
+func (s Silence) isActive(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+func (s Silence) matches(device string, ruleID string) bool {
+	if len(s.Device) > 0 {
+		matched, err := path.Match(s.Device, device)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	if len(s.RuleID) > 0 && s.RuleID != ruleID {
+		return false
+	}
+	return true
+}
+
+func AddSilence(silence Silence) (Silence, error) {
+	if len(silence.Device) == 0 && len(silence.RuleID) == 0 {
+		return silence, fmt.Errorf("silence must match device or rule")
+	}
+	if _, err := path.Match(silence.Device, ""); err != nil {
+		return silence, fmt.Errorf("invalid device pattern %q: %w", silence.Device, err)
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = time.Now()
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return silence, fmt.Errorf("silence must end after it starts")
+	}
+	silence.ID = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	silencesLock.Lock()
+	defer silencesLock.Unlock()
+	silenceStorage.Silences = append(silenceStorage.Silences, silence)
+	silenceStorage.store()
+	slog.Info("SILENCE - Added.", "silence", silence)
+	return silence, nil
+}
+
+// Active and pending silences
+func ListSilences() []Silence {
+	silencesLock.Lock()
+	defer silencesLock.Unlock()
+	return append([]Silence{}, silenceStorage.Silences...)
+}
+
+func ExpireSilence(id string) error {
+	silencesLock.Lock()
+	for idx, silence := range silenceStorage.Silences {
+		if silence.ID == id {
+			silenceStorage.Silences = append(silenceStorage.Silences[:idx], silenceStorage.Silences[idx+1:]...)
+			silenceStorage.store()
+			silencesLock.Unlock()
+			slog.Info("SILENCE - Expired.", "silence", silence)
+			releaseHeldFiringNotifications()
+			return nil
+		}
+	}
+	silencesLock.Unlock()
+	return fmt.Errorf("silence %q not found", id)
+}
+
+func isSilenced(device string, ruleID string) bool {
+	silencesLock.Lock()
+	defer silencesLock.Unlock()
+	now := time.Now()
+	for _, silence := range silenceStorage.Silences {
+		if silence.isActive(now) && silence.matches(device, ruleID) {
+			slog.Debug("SILENCE - Notification muted.", "device", device, "rule", ruleID, "silence", silence)
+			return true
+		}
+	}
+	return false
+}
+
+func removeExpiredSilences() {
+	silencesLock.Lock()
+	defer silencesLock.Unlock()
+	now := time.Now()
+	var silences []Silence
+	for _, silence := range silenceStorage.Silences {
+		if now.Before(silence.EndsAt) {
+			silences = append(silences, silence)
+		} else {
+			slog.Info("SILENCE - Expired.", "silence", silence)
+		}
+	}
+	if len(silences) != len(silenceStorage.Silences) {
+		silenceStorage.Silences = silences
+		silenceStorage.store()
+	}
+}
+
+func (silences Silences) store() {
+	jsonData, err := json.Marshal(silences)
+	if err != nil {
+		slog.Error("Error encoding JSON when storing silences.", "error", err)
+		return
+	}
+	if err := os.WriteFile(silencesStorage, jsonData, 0644); err != nil {
+		slog.Error("Error writing silences to file.", "file", silencesStorage, "error", err)
+	}
+}
+
+func readSilences() Silences {
+	jsonData, err := os.ReadFile(silencesStorage)
+	if err != nil {
+		slog.Debug("File with stored silences does not exist or is not readable.")
+		return Silences{}
+	}
+	var restoredData Silences
+	if err := json.Unmarshal(jsonData, &restoredData); err != nil {
+		slog.Error("Error decoding JSON from stored silences.", "error", err)
+		return Silences{}
+	}
+	slog.Info("Silences loaded.", "file", silencesStorage, "count", len(restoredData.Silences))
+	return restoredData
+}
@@ -0,0 +1,196 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jorycz/tasmota-alerter/pkg/notificationengine"
+	"github.com/jorycz/tasmota-alerter/pkg/ruleengine"
+)
+
+func TestSilenceMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		silence Silence
+		device  string
+		ruleID  string
+		want    bool
+	}{
+		{name: "device", silence: Silence{Device: "plug-fridge"}, device: "plug-fridge", ruleID: "fridge-power", want: true},
+		{name: "other device", silence: Silence{Device: "plug-fridge"}, device: "plug-freezer", ruleID: "fridge-power"},
+		{name: "device glob", silence: Silence{Device: "plug-*"}, device: "plug-freezer", ruleID: "freezer-stopped", want: true},
+		{name: "device glob does not match", silence: Silence{Device: "plug-*"}, device: "switch-garage", ruleID: "offline"},
+		{name: "single character glob", silence: Silence{Device: "plug-?"}, device: "plug-1", want: true},
+		{name: "rule on any device", silence: Silence{RuleID: "offline"}, device: "switch-garage", ruleID: "offline", want: true},
+		{name: "other rule", silence: Silence{RuleID: "offline"}, device: "switch-garage", ruleID: "power"},
+		{name: "device and rule", silence: Silence{Device: "plug-*", RuleID: "offline"}, device: "plug-fridge", ruleID: "offline", want: true},
+		{name: "device and other rule", silence: Silence{Device: "plug-*", RuleID: "offline"}, device: "plug-fridge", ruleID: "power"},
+		{name: "invalid glob", silence: Silence{Device: "plug-["}, device: "plug-[", ruleID: "power"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.silence.matches(tt.device, tt.ruleID); got != tt.want {
+				t.Errorf("matches(%q, %q) = %v, want %v", tt.device, tt.ruleID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSilenceIsActive(t *testing.T) {
+	now := time.Now()
+	silence := Silence{StartsAt: now, EndsAt: now.Add(time.Hour)}
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{at: now.Add(-time.Second), want: false},
+		{at: now, want: true},
+		{at: now.Add(59 * time.Minute), want: true},
+		{at: now.Add(time.Hour), want: false},
+	}
+	for _, tt := range tests {
+		if got := silence.isActive(tt.at); got != tt.want {
+			t.Errorf("isActive(%v) = %v, want %v", tt.at.Sub(now), got, tt.want)
+		}
+	}
+}
+
+func TestAddSilence(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		silence Silence
+		wantErr bool
+	}{
+		{name: "device for an hour", silence: Silence{Device: "plug-*", EndsAt: now.Add(time.Hour)}},
+		{name: "rule in the future", silence: Silence{RuleID: "offline", StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)}},
+		{name: "nothing to match", silence: Silence{EndsAt: now.Add(time.Hour)}, wantErr: true},
+		{name: "invalid glob", silence: Silence{Device: "plug-[", EndsAt: now.Add(time.Hour)}, wantErr: true},
+		{name: "already ended", silence: Silence{Device: "plug-fridge", EndsAt: now.Add(-time.Hour)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			silenceStorage = Silences{}
+			added, err := AddSilence(tt.silence)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddSilence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(ListSilences()) > 0 {
+					t.Errorf("invalid silence was added")
+				}
+				return
+			}
+			if len(added.ID) == 0 || added.StartsAt.IsZero() {
+				t.Errorf("added silence has no ID or start: %+v", added)
+			}
+			if err := ExpireSilence(added.ID); err != nil {
+				t.Errorf("ExpireSilence() error = %v", err)
+			}
+			if err := ExpireSilence(added.ID); err == nil {
+				t.Errorf("ExpireSilence() of removed silence succeeded")
+			}
+		})
+	}
+}
+
+func TestIsSilenced(t *testing.T) {
+	now := time.Now()
+	silenceStorage = Silences{Silences: []Silence{
+		{ID: "active", Device: "plug-*", RuleID: "offline", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)},
+		{ID: "pending", Device: "switch-garage", StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)},
+		{ID: "expired", RuleID: "power", StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+	}}
+	tests := []struct {
+		device string
+		ruleID string
+		want   bool
+	}{
+		{device: "plug-fridge", ruleID: "offline", want: true},
+		{device: "plug-fridge", ruleID: "power"},
+		{device: "switch-garage", ruleID: "offline"},
+	}
+	for _, tt := range tests {
+		if got := isSilenced(tt.device, tt.ruleID); got != tt.want {
+			t.Errorf("isSilenced(%q, %q) = %v, want %v", tt.device, tt.ruleID, got, tt.want)
+		}
+	}
+
+	removeExpiredSilences()
+	var ids []string
+	for _, silence := range ListSilences() {
+		ids = append(ids, silence.ID)
+	}
+	if len(ids) != 2 || ids[0] != "active" || ids[1] != "pending" {
+		t.Errorf("silences after removing expired = %v, want [active pending]", ids)
+	}
+}
+
+func TestNotifyRuleChannelsSilenced(t *testing.T) {
+	now := time.Now()
+	silenceStorage = Silences{Silences: []Silence{{ID: "active", Device: "plug-*", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}}}
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}}
+	notificationQueue = nil
+	rule := ruleengine.Rule{ID: "power", Recipients: "TEST"}
+
+	if notifyRuleChannels("plug-fridge", rule, notificationengine.Notification{State: notificationengine.StateFiring, Value: "2500", Message: "Power is high."}) {
+		t.Error("notification of silenced device was sent")
+	}
+	if !notifyRuleChannels("switch-garage", rule, notificationengine.Notification{State: notificationengine.StateFiring, Value: "2500", Message: "Power is high."}) {
+		t.Error("notification of device without silence was not sent")
+	}
+	if len(notificationQueue) != 1 || notificationQueue[0].notification.Device != "switch-garage" {
+		t.Errorf("queued notifications = %+v, want one of switch-garage", notificationQueue)
+	}
+}
+
+func TestSilencedFiringIsSentWhenSilenceEnds(t *testing.T) {
+	loadTestRules(t, "0:::plug-fridge:::ENERGY-->Power:::<1:::TEST:::Fridge is not running.:::Fridge is running.:::id=power-low")
+	t.Cleanup(func() { loadTestRules(t) })
+	rule := ruleengine.MonitoringRulesByDevice()["plug-fridge"][0]
+	now := time.Now()
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}, FlapStates: map[string]FlapState{}}
+	silenceStorage = Silences{Silences: []Silence{{ID: "maintenance", Device: "plug-*", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}}}
+	notificationQueue = nil
+
+	notifyMonitoredValueArrived("plug-fridge", "0.000", rule)
+	releaseHeldFiringNotifications()
+	if len(notificationQueue) != 0 {
+		t.Fatalf("notification of silenced alert was sent: %+v", notificationQueue)
+	}
+
+	if err := ExpireSilence("maintenance"); err != nil {
+		t.Fatal(err)
+	}
+	if len(notificationQueue) != 1 || notificationQueue[0].notification.State != notificationengine.StateFiring {
+		t.Fatalf("queued notifications = %+v, want firing", notificationQueue)
+	}
+	if !strings.Contains(notificationQueue[0].notification.Message, "Fridge is not running. Alert is active since") {
+		t.Errorf("unexpected message %q", notificationQueue[0].notification.Message)
+	}
+	notificationQueue = nil
+
+	removeAlertIfNotifiedBefore("plug-fridge", "1.000", rule)
+	if len(notificationQueue) != 1 || notificationQueue[0].notification.State != notificationengine.StateResolved {
+		t.Errorf("queued notifications = %+v, want resolved", notificationQueue)
+	}
+}
+
+func TestSilencedAlertResolvesWithoutNotification(t *testing.T) {
+	loadTestRules(t, "0:::plug-fridge:::ENERGY-->Power:::<1:::TEST:::Fridge is not running.:::Fridge is running.:::id=power-low")
+	t.Cleanup(func() { loadTestRules(t) })
+	rule := ruleengine.MonitoringRulesByDevice()["plug-fridge"][0]
+	now := time.Now()
+	firedAlertStorage = Alerts{FiredAlerts: map[string][]Alert{}, FlapStates: map[string]FlapState{}}
+	silenceStorage = Silences{Silences: []Silence{{ID: "maintenance", RuleID: "power-low", StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}}}
+	notificationQueue = nil
+
+	notifyMonitoredValueArrived("plug-fridge", "0.000", rule)
+	silenceStorage = Silences{}
+	removeAlertIfNotifiedBefore("plug-fridge", "1.000", rule)
+
+	if len(notificationQueue) != 0 {
+		t.Errorf("queued notifications = %+v, want none", notificationQueue)
+	}
+}
@@ -0,0 +1,76 @@
+package ruleengine
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	actionRuleTag    = "__ACTION__"
+	ActionOnFire     = "fire"
+	ActionOnResolved = "resolve"
+)
+
+// Commands published to MQTT when alert of rule on device is fired or resolved
+type RuleAction struct {
+	Device string
+	RuleID string
+	On     string
+	Steps  []ActionStep
+}
+
+// Step is either MQTT command (topic and payload) or delay before next step
+type ActionStep struct {
+	Topic   string
+	Payload string
+	Delay   time.Duration
+}
+
+var ruleActions []RuleAction
+
+func ActionsForRule(device string, ruleID string, on string) []RuleAction {
+	lock.Lock()
+	defer lock.Unlock()
+	var actions []RuleAction
+	for _, action := range ruleActions {
+		if action.Device == device && action.RuleID == ruleID && action.On == on {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// __ACTION__:::device:::rule ID:::fire or resolve:::cmnd/plug-heater/POWER OFF:::5s:::cmnd/plug-fan/POWER ON
+func parseRuleAction(line string, parsed []string) {
+	if len(parsed) < 5 {
+		slog.Error("Can not parse rule action! Expected device, rule ID, fire or resolve and at least one command after "+actionRuleTag, "rule_line", line)
+		return
+	}
+	a := RuleAction{}
+	a.Device = strings.TrimSpace(parsed[1])
+	a.RuleID = strings.TrimSpace(parsed[2])
+	a.On = strings.ToLower(strings.TrimSpace(parsed[3]))
+	if a.On != ActionOnFire && a.On != ActionOnResolved {
+		slog.Error("Can not parse rule action! Use fire or resolve.", "rule_line", line)
+		return
+	}
+
+	for _, stepStr := range parsed[4:] {
+		stepStr = strings.TrimSpace(stepStr)
+		if delay, err := time.ParseDuration(stepStr); err == nil {
+			a.Steps = append(a.Steps, ActionStep{Delay: delay})
+			continue
+		}
+		topic, payload, _ := strings.Cut(stepStr, " ")
+		if !strings.HasPrefix(topic, "cmnd/") {
+			slog.Error("Can not parse rule action step! Use command like cmnd/plug-heater/POWER OFF or delay like 5s.", "rule_line", line, "step", stepStr)
+			return
+		}
+		a.Steps = append(a.Steps, ActionStep{Topic: topic, Payload: strings.TrimSpace(payload)})
+	}
+
+	lock.Lock()
+	ruleActions = append(ruleActions, a)
+	lock.Unlock()
+}
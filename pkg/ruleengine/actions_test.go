@@ -0,0 +1,75 @@
+package ruleengine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRuleAction(t *testing.T) {
+	t.Cleanup(func() { ruleActions = nil })
+	tests := []struct {
+		line    string
+		want    RuleAction
+		wantErr bool
+	}{
+		{
+			line: "__ACTION__:::plug:::overload:::fire:::cmnd/plug/POWER OFF",
+			want: RuleAction{Device: "plug", RuleID: "overload", On: ActionOnFire, Steps: []ActionStep{{Topic: "cmnd/plug/POWER", Payload: "OFF"}}},
+		},
+		{
+			line: "__ACTION__:::plug:::overload:::Resolve:::cmnd/heater/POWER OFF:::5s:::cmnd/fan/POWER ON",
+			want: RuleAction{Device: "plug", RuleID: "overload", On: ActionOnResolved, Steps: []ActionStep{
+				{Topic: "cmnd/heater/POWER", Payload: "OFF"},
+				{Delay: 5 * time.Second},
+				{Topic: "cmnd/fan/POWER", Payload: "ON"},
+			}},
+		},
+		{
+			line: "__ACTION__:::plug:::overload:::fire:::cmnd/plug/STATUS",
+			want: RuleAction{Device: "plug", RuleID: "overload", On: ActionOnFire, Steps: []ActionStep{{Topic: "cmnd/plug/STATUS"}}},
+		},
+		{line: "__ACTION__:::plug:::overload:::always:::cmnd/plug/POWER OFF", wantErr: true},
+		{line: "__ACTION__:::plug:::overload:::fire:::stat/plug/POWER OFF", wantErr: true},
+		{line: "__ACTION__:::plug:::overload:::fire", wantErr: true},
+	}
+	for _, tt := range tests {
+		ruleActions = nil
+		parseRuleAction(tt.line, strings.Split(tt.line, ":::"))
+		if tt.wantErr {
+			if len(ruleActions) != 0 {
+				t.Errorf("parseRuleAction(%q) added %+v, want error", tt.line, ruleActions)
+			}
+			continue
+		}
+		if len(ruleActions) != 1 || !reflect.DeepEqual(ruleActions[0], tt.want) {
+			t.Errorf("parseRuleAction(%q) = %+v, want %+v", tt.line, ruleActions, tt.want)
+		}
+	}
+}
+
+func TestActionsForRule(t *testing.T) {
+	t.Cleanup(func() { ruleActions = nil })
+	ruleActions = []RuleAction{
+		{Device: "plug", RuleID: "overload", On: ActionOnFire},
+		{Device: "plug", RuleID: "overload", On: ActionOnResolved},
+		{Device: "lamp", RuleID: "overload", On: ActionOnFire},
+	}
+	tests := []struct {
+		device string
+		ruleID string
+		on     string
+		want   int
+	}{
+		{device: "plug", ruleID: "overload", on: ActionOnFire, want: 1},
+		{device: "plug", ruleID: "overload", on: ActionOnResolved, want: 1},
+		{device: "plug", ruleID: "offline", on: ActionOnFire, want: 0},
+		{device: "heater", ruleID: "overload", on: ActionOnFire, want: 0},
+	}
+	for _, tt := range tests {
+		if got := ActionsForRule(tt.device, tt.ruleID, tt.on); len(got) != tt.want {
+			t.Errorf("ActionsForRule(%v, %v, %v) returned %v actions, want %v", tt.device, tt.ruleID, tt.on, len(got), tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ruleengine
+
+import (
+	"log/slog"
+	"path"
+	"strings"
+)
+
+const (
+	inhibitRuleTag = "__INHIBIT__"
+	// Target device of inhibit rule is the same device as the one with firing source alert
+	InhibitSameDeviceTag = "__SAME__"
+)
+
+// While alert of SourceRuleID fires on device matching SourceDevice glob,
+// notifications of rules matching TargetRuleID glob on devices matching TargetDevice glob are suppressed.
+type InhibitRule struct {
+	SourceDevice string
+	SourceRuleID string
+	TargetDevice string
+	TargetRuleID string
+}
+
+var inhibitRules []InhibitRule
+
+func InhibitRules() []InhibitRule {
+	lock.Lock()
+	defer lock.Unlock()
+	return inhibitRules
+}
+
+// __INHIBIT__:::source device glob:::source rule ID:::target device glob or __SAME__:::target rule ID glob
+func parseInhibitRule(line string, parsed []string) {
+	if len(parsed) != 5 {
+		slog.Error("Can not parse inhibit rule! Expected 4 fields after "+inhibitRuleTag, "rule_line", line)
+		return
+	}
+	i := InhibitRule{}
+	i.SourceDevice = strings.TrimSpace(parsed[1])
+	i.SourceRuleID = strings.TrimSpace(parsed[2])
+	i.TargetDevice = strings.TrimSpace(parsed[3])
+	i.TargetRuleID = strings.TrimSpace(parsed[4])
+	for _, pattern := range []string{i.SourceDevice, i.TargetDevice, i.TargetRuleID} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			slog.Error("Can not parse inhibit rule pattern!", "rule_line", line, "pattern", pattern, "error", err)
+			return
+		}
+	}
+
+	lock.Lock()
+	inhibitRules = append(inhibitRules, i)
+	lock.Unlock()
+}
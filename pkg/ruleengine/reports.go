@@ -0,0 +1,66 @@
+package ruleengine
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const reportRuleTag = "__REPORT__"
+
+// Summary report sent every day or every week on Weekday (when Weekly) at Hour:Minute
+type ReportSchedule struct {
+	Weekly     bool
+	Weekday    time.Weekday
+	Hour       int
+	Minute     int
+	Recipients string
+}
+
+var reportSchedules []ReportSchedule
+
+func ReportSchedules() []ReportSchedule {
+	lock.Lock()
+	defer lock.Unlock()
+	return reportSchedules
+}
+
+// __REPORT__:::daily or weekday name (monday, ...):::07:00:::EMAIL_...,TELEGRAM_...
+func parseReportSchedule(line string, parsed []string) {
+	if len(parsed) != 4 {
+		slog.Error("Can not parse report schedule! Expected 3 fields after "+reportRuleTag, "rule_line", line)
+		return
+	}
+	r := ReportSchedule{}
+	period := strings.ToLower(strings.TrimSpace(parsed[1]))
+	if period != "daily" {
+		weekday, found := parseWeekday(period)
+		if !found {
+			slog.Error("Can not parse report period! Use daily or name of weekday.", "rule_line", line, "period", period)
+			return
+		}
+		r.Weekly = true
+		r.Weekday = weekday
+	}
+	at, err := time.Parse("15:04", strings.TrimSpace(parsed[2]))
+	if err != nil {
+		slog.Error("Can not parse report time! Use format like 07:00.", "rule_line", line, "error", err)
+		return
+	}
+	r.Hour = at.Hour()
+	r.Minute = at.Minute()
+	r.Recipients = strings.TrimSpace(parsed[3])
+
+	lock.Lock()
+	reportSchedules = append(reportSchedules, r)
+	lock.Unlock()
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.ToLower(weekday.String()) == name {
+			return weekday, true
+		}
+	}
+	return time.Sunday, false
+}
@@ -0,0 +1,35 @@
+package ruleengine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReportSchedule(t *testing.T) {
+	t.Cleanup(func() { reportSchedules = nil })
+	tests := []struct {
+		line    string
+		want    ReportSchedule
+		wantErr bool
+	}{
+		{line: "__REPORT__:::daily:::07:00:::EMAIL_A", want: ReportSchedule{Hour: 7, Recipients: "EMAIL_A"}},
+		{line: "__REPORT__:::Monday:::18:30:::EMAIL_A,TELEGRAM_B", want: ReportSchedule{Weekly: true, Weekday: time.Monday, Hour: 18, Minute: 30, Recipients: "EMAIL_A,TELEGRAM_B"}},
+		{line: "__REPORT__:::someday:::07:00:::EMAIL_A", wantErr: true},
+		{line: "__REPORT__:::daily:::7am:::EMAIL_A", wantErr: true},
+		{line: "__REPORT__:::daily:::07:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		reportSchedules = nil
+		parseReportSchedule(tt.line, strings.Split(tt.line, ":::"))
+		if tt.wantErr {
+			if len(reportSchedules) != 0 {
+				t.Errorf("parseReportSchedule(%q) added %+v, want error", tt.line, reportSchedules)
+			}
+			continue
+		}
+		if len(reportSchedules) != 1 || reportSchedules[0] != tt.want {
+			t.Errorf("parseReportSchedule(%q) = %+v, want %+v", tt.line, reportSchedules, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ruleengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severities of rules
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Rule options are optional fields at the end of the rule line in key=value format, e.g. repeat=1h:::repeat_max=3
+func parseRuleOption(r *Rule, option string) error {
+	key, value, found := strings.Cut(option, "=")
+	if !found {
+		return fmt.Errorf("option %q is not in key=value format", option)
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "id":
+		if len(value) == 0 {
+			return fmt.Errorf("rule id can't be empty")
+		}
+		r.ID = value
+	case "depends":
+		r.DependsOn = value
+	case "repeat":
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("can't parse repeat interval %q: %w", value, err)
+		}
+		r.RepeatInterval = interval
+	case "repeat_max":
+		repeatMax, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return fmt.Errorf("can't parse maximum count of reminders %q: %w", value, err)
+		}
+		r.RepeatMax = repeatMax
+	case "flap":
+		// Count of fire / resolve transitions in time window, like 6/1h
+		thresholdStr, windowStr, found := strings.Cut(value, "/")
+		if !found {
+			return fmt.Errorf("flapping detection %q must be in format transitions/window, like 6/1h", value)
+		}
+		threshold, err := strconv.ParseInt(thresholdStr, 0, 64)
+		if err != nil || threshold < 2 {
+			return fmt.Errorf("flapping threshold %q must be a number 2 or higher", thresholdStr)
+		}
+		window, err := time.ParseDuration(windowStr)
+		if err != nil {
+			return fmt.Errorf("can't parse flapping window %q: %w", windowStr, err)
+		}
+		r.FlapThreshold = threshold
+		r.FlapWindow = window
+	case "severity":
+		severity, err := parseSeverity(value)
+		if err != nil {
+			return err
+		}
+		r.Severity = severity
+	default:
+		return fmt.Errorf("unknown rule option %q", key)
+	}
+	return nil
+}
+
+func parseSeverity(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+		return strings.ToLower(value), nil
+	}
+	return "", fmt.Errorf("severity %q must be %v, %v or %v", value, SeverityInfo, SeverityWarning, SeverityCritical)
+}
+
+// Rule without id option is identified by JSON path (or event tag) and compared value, e.g. ENERGY-->Power>1500
+func defaultRuleID(r Rule) string {
+	return r.JsonPathOrEventTag + r.CompareValue
+}
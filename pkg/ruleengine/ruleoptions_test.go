@@ -0,0 +1,53 @@
+package ruleengine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRuleOption(t *testing.T) {
+	tests := []struct {
+		option  string
+		want    Rule
+		wantErr bool
+	}{
+		{option: "id=heater-on", want: Rule{ID: "heater-on"}},
+		{option: " id = heater-on ", want: Rule{ID: "heater-on"}},
+		{option: "id=", wantErr: true},
+		{option: "depends=offline", want: Rule{DependsOn: "offline"}},
+		{option: "repeat=1h", want: Rule{RepeatInterval: time.Hour}},
+		{option: " repeat = 30m ", want: Rule{RepeatInterval: 30 * time.Minute}},
+		{option: "repeat=hourly", wantErr: true},
+		{option: "repeat_max=3", want: Rule{RepeatMax: 3}},
+		{option: "repeat_max=many", wantErr: true},
+		{option: "flap=6/1h", want: Rule{FlapThreshold: 6, FlapWindow: time.Hour}},
+		{option: "flap=6", wantErr: true},
+		{option: "flap=1/1h", wantErr: true},
+		{option: "flap=6/hour", wantErr: true},
+		{option: "severity=critical", want: Rule{Severity: SeverityCritical}},
+		{option: "severity=INFO", want: Rule{Severity: SeverityInfo}},
+		{option: "severity=fatal", wantErr: true},
+		{option: "repeat", wantErr: true},
+		{option: "color=red", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.option, func(t *testing.T) {
+			var r Rule
+			err := parseRuleOption(&r, tt.option)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRuleOption(%q) error = %v, wantErr %v", tt.option, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(r, tt.want) {
+				t.Errorf("parseRuleOption(%q) = %+v, want %+v", tt.option, r, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRuleID(t *testing.T) {
+	r := Rule{JsonPathOrEventTag: "ENERGY-->Power", CompareValue: ">1500"}
+	if got := defaultRuleID(r); got != "ENERGY-->Power>1500" {
+		t.Errorf("defaultRuleID() = %q, want %q", got, "ENERGY-->Power>1500")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jorycz/tasmota-alerter/pkg/utils"
 )
@@ -16,12 +17,20 @@ var (
 )
 
 type Rule struct {
-	IgnoreOccurrences	int64
+	ID                  string
+	IgnoreOccurrences   int64
 	JsonPathOrEventTag  string
 	CompareValue        string
 	Recipients          string
 	MessageRuleActive   string
 	MessageRuleInActive string
+	DependsOn           string
+	RepeatInterval      time.Duration
+	RepeatMax           int64
+	FlapThreshold       int64
+	FlapWindow          time.Duration
+	// info, warning or critical (default is warning)
+	Severity string
 }
 
 type Rules struct {
@@ -36,6 +45,17 @@ func NewRules() *Rules {
 	return &Rules{monitoringRules}
 }
 
+// Copy of monitoring rules by device
+func MonitoringRulesByDevice() map[string][]Rule {
+	lock.Lock()
+	defer lock.Unlock()
+	rules := make(map[string][]Rule, len(monitoringRules))
+	for device, rulesOfDevice := range monitoringRules {
+		rules[device] = append([]Rule{}, rulesOfDevice...)
+	}
+	return rules
+}
+
 func RefreshRules() {
 	readRuleFiles()
 }
@@ -44,7 +64,7 @@ func RefreshRules() {
 func readRuleFiles() {
 	ruleFilesLines, err := utils.ReadFilesWithSuffix("rules", ".conf")
 	if err != nil {
-		slog.Error("Error when reading RULE FILES", err)
+		slog.Error("Error when reading RULE FILES", "error", err)
 	}
 	createUniversalRuleSet(ruleFilesLines)
 }
@@ -54,6 +74,10 @@ func createUniversalRuleSet(ruleLines []string) {
 	for k := range monitoringRules {
 		delete(monitoringRules, k)
 	}
+	inhibitRules = nil
+	reportSchedules = nil
+	ruleActions = nil
+	safetyRules = make(map[string][]SafetyRule)
 	lock.Unlock()
 
 	rulesProcessed = incrementSeqNumber()
@@ -61,6 +85,22 @@ func createUniversalRuleSet(ruleLines []string) {
 	for _, line := range ruleLines {
 		slog.Debug("Loading monitoring rule.", "data", line)
 		parsed := strings.Split(line, ":::")
+		if parsed[0] == inhibitRuleTag {
+			parseInhibitRule(line, parsed)
+			continue
+		}
+		if parsed[0] == reportRuleTag {
+			parseReportSchedule(line, parsed)
+			continue
+		}
+		if parsed[0] == actionRuleTag {
+			parseRuleAction(line, parsed)
+			continue
+		}
+		if parsed[0] == safetyRuleTag {
+			parseSafetyRule(line, parsed)
+			continue
+		}
 		if len(parsed) > 3 {
 
 			ignoreCount, err := strconv.ParseInt(strings.Split(line, ":::")[0], 0, 64)
@@ -85,6 +125,20 @@ func createUniversalRuleSet(ruleLines []string) {
 			if len(parsed) > 6 {
 				r.MessageRuleInActive = strings.Split(line, ":::")[6]
 			}
+			// Everything after notification texts are optional key=value rule options
+			if len(parsed) > 7 {
+				for _, option := range parsed[7:] {
+					if err := parseRuleOption(&r, option); err != nil {
+						slog.Error("Can not parse rule option!", "rule_line", line, "error", err)
+					}
+				}
+			}
+			if len(r.ID) == 0 {
+				r.ID = defaultRuleID(r)
+			}
+			if len(r.Severity) == 0 {
+				r.Severity = SeverityWarning
+			}
 			monitoringRules[device] = append(monitoringRules[device], r)
 			_ = rulesProcessed()
 		} else {
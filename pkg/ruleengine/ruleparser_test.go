@@ -0,0 +1,167 @@
+package ruleengine
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Commented examples of shipped rule files, like "# 0:::plug-fridge:::..."
+func exampleRuleLines(t *testing.T, file string) []string {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line, found := strings.CutPrefix(scanner.Text(), "# "); found && strings.Contains(line, ":::") {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+// Parse rule lines and return logged warnings and errors
+func parseRuleLines(t *testing.T, lines []string) string {
+	t.Helper()
+	var logged bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logged, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	monitoringRules = map[string][]Rule{}
+	createUniversalRuleSet(lines)
+	return logged.String()
+}
+
+func TestShippedExampleRules(t *testing.T) {
+	files, err := filepath.Glob("../../rules/*.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no rule files found")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			lines := exampleRuleLines(t, file)
+			if len(lines) == 0 {
+				t.Skip("no examples")
+			}
+			if logged := parseRuleLines(t, lines); len(logged) > 0 {
+				t.Errorf("examples of %v are not valid:\n%v", file, logged)
+			}
+		})
+	}
+}
+
+func TestParseRuleLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Rule
+	}{
+		{
+			name: "messages",
+			line: "1:::plug-washing-machine:::ENERGY-->Power:::>1500:::EMAIL_PARENTS:::Heating.:::Heating is complete.",
+			want: Rule{ID: "ENERGY-->Power>1500", IgnoreOccurrences: 1, JsonPathOrEventTag: "ENERGY-->Power", CompareValue: ">1500",
+				Recipients: "EMAIL_PARENTS", MessageRuleActive: "Heating.", MessageRuleInActive: "Heating is complete.", Severity: SeverityWarning},
+		},
+		{
+			name: "options without inactive message",
+			line: "0:::plug-washing-machine:::ENERGY-->Power:::<1:::TELEGRAM_HOME:::Not running.::::::depends=offline",
+			want: Rule{ID: "ENERGY-->Power<1", JsonPathOrEventTag: "ENERGY-->Power", CompareValue: "<1",
+				Recipients: "TELEGRAM_HOME", MessageRuleActive: "Not running.", DependsOn: "offline", Severity: SeverityWarning},
+		},
+		{
+			name: "severity",
+			line: "0:::plug-washing-machine:::ENERGY-->Power:::>2000:::TELEGRAM_HOME:::Overload.::::::severity=critical",
+			want: Rule{ID: "ENERGY-->Power>2000", JsonPathOrEventTag: "ENERGY-->Power", CompareValue: ">2000",
+				Recipients: "TELEGRAM_HOME", MessageRuleActive: "Overload.", Severity: SeverityCritical},
+		},
+		{
+			name: "minimal",
+			line: "3:::plug-washing-machine:::ENERGY-->Power:::<3",
+			want: Rule{ID: "ENERGY-->Power<3", IgnoreOccurrences: 3, JsonPathOrEventTag: "ENERGY-->Power", CompareValue: "<3", Severity: SeverityWarning},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if logged := parseRuleLines(t, []string{tt.line}); len(logged) > 0 {
+				t.Fatalf("unexpected log:\n%v", logged)
+			}
+			rules := monitoringRules["plug-washing-machine"]
+			if len(rules) != 1 {
+				t.Fatalf("got %v rules, want 1", len(rules))
+			}
+			if rules[0] != tt.want {
+				t.Errorf("got %+v, want %+v", rules[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRuleLineErrors(t *testing.T) {
+	for _, line := range []string{
+		"x:::plug-washing-machine:::ENERGY-->Power:::<3",
+		"0:::plug-washing-machine:::ENERGY-->Power",
+		"0:::plug-washing-machine:::ENERGY-->Power:::<1:::TELEGRAM_HOME:::Not running.:::::::depends=offline",
+	} {
+		t.Run(line, func(t *testing.T) {
+			if logged := parseRuleLines(t, []string{line}); len(logged) == 0 {
+				t.Errorf("rule line %q was parsed without error", line)
+			}
+		})
+	}
+}
+
+func TestParseInhibitRule(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []InhibitRule
+		wantLog bool
+	}{
+		{line: "__INHIBIT__:::plug-*:::offline:::__SAME__:::*", want: []InhibitRule{{SourceDevice: "plug-*", SourceRuleID: "offline", TargetDevice: InhibitSameDeviceTag, TargetRuleID: "*"}}},
+		{line: "__INHIBIT__::: plug-main-breaker ::: breaker-tripped ::: plug-* ::: *", want: []InhibitRule{{SourceDevice: "plug-main-breaker", SourceRuleID: "breaker-tripped", TargetDevice: "plug-*", TargetRuleID: "*"}}},
+		{line: "__INHIBIT__:::plug-*:::offline:::__SAME__", wantLog: true},
+		{line: "__INHIBIT__:::plug-[:::offline:::__SAME__:::*", wantLog: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			logged := parseRuleLines(t, []string{tt.line})
+			if (len(logged) > 0) != tt.wantLog {
+				t.Errorf("logged %q, want log %v", logged, tt.wantLog)
+			}
+			if got := InhibitRules(); len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("InhibitRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonitoringRulesByDevice(t *testing.T) {
+	parseRuleLines(t, []string{
+		"0:::plug-fridge:::ENERGY-->Power:::<1:::TEST",
+		"0:::plug-fridge:::ENERGY-->Power:::>1500:::TEST",
+		"0:::plug-washing-machine:::ENERGY-->Power:::>0:::TEST",
+	})
+	rules := MonitoringRulesByDevice()
+	if len(rules) != 2 || len(rules["plug-fridge"]) != 2 || len(rules["plug-washing-machine"]) != 1 {
+		t.Fatalf("MonitoringRulesByDevice() = %+v, want 2 rules of plug-fridge and 1 of plug-washing-machine", rules)
+	}
+	// Returned rules are a copy
+	rules["plug-fridge"][0].ID = "changed"
+	if monitoringRules["plug-fridge"][0].ID == "changed" {
+		t.Error("MonitoringRulesByDevice() returned rules shared with the rule engine")
+	}
+}
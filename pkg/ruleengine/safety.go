@@ -0,0 +1,129 @@
+package ruleengine
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const safetyRuleTag = "__SAFETY__"
+
+// Device with cooldown and without retries option is switched on again after every shutoff
+const UnlimitedRetries = -1
+
+// Tasmota sends telemetry (SENSOR) every TelePeriod, 300 seconds by default
+const defaultTelePeriod = 300 * time.Second
+
+// Device is switched off when value on JSON path meets condition for duration For.
+// Condition is checked only when telemetry arrives, TelePeriod is telemetry period set on the device (0 means Tasmota default).
+// With Cooldown the device is switched on again after cooldown, at most Retries times (unless UnlimitedRetries), then it is locked out until manual reset.
+type SafetyRule struct {
+	ID           string
+	JsonPath     string
+	CompareValue string
+	For          time.Duration
+	Recipients   string
+	Cooldown     time.Duration
+	Retries      int
+	TelePeriod   time.Duration
+}
+
+var safetyRules map[string][]SafetyRule
+
+func SafetyRulesForDevice(device string) []SafetyRule {
+	lock.Lock()
+	defer lock.Unlock()
+	return safetyRules[device]
+}
+
+func SafetyRuleByID(device string, ruleID string) (SafetyRule, bool) {
+	for _, rule := range SafetyRulesForDevice(device) {
+		if rule.ID == ruleID {
+			return rule, true
+		}
+	}
+	return SafetyRule{}, false
+}
+
+// __SAFETY__:::device:::JSON path:::>2000:::30s:::EMAIL_...,TELEGRAM_...:::id=...:::cooldown=10m:::retries=3:::teleperiod=10s
+func parseSafetyRule(line string, parsed []string) {
+	if len(parsed) < 6 {
+		slog.Error("Can not parse safety rule! Expected device, JSON path, condition, duration and channels after "+safetyRuleTag, "rule_line", line)
+		return
+	}
+	device := strings.TrimSpace(parsed[1])
+	r := SafetyRule{Retries: UnlimitedRetries}
+	r.JsonPath = strings.TrimSpace(parsed[2])
+	r.CompareValue = strings.TrimSpace(parsed[3])
+	if len(r.CompareValue) < 2 || !strings.Contains("<>=", r.CompareValue[:1]) {
+		slog.Error("Can not parse safety rule condition! Use >, < or = with value.", "rule_line", line)
+		return
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(parsed[4]))
+	if err != nil {
+		slog.Error("Can not parse safety rule duration!", "rule_line", line, "error", err)
+		return
+	}
+	r.For = duration
+	r.Recipients = strings.TrimSpace(parsed[5])
+
+	for _, option := range parsed[6:] {
+		if err := parseSafetyRuleOption(&r, option); err != nil {
+			slog.Error("Can not parse safety rule option!", "rule_line", line, "error", err)
+		}
+	}
+	if len(r.ID) == 0 {
+		r.ID = "safety:" + r.JsonPath + r.CompareValue
+	}
+	if r.Cooldown == 0 && r.Retries != UnlimitedRetries {
+		slog.Warn("Safety rule has retries without cooldown. Device stays off after shutoff, retries are ignored.", "rule_line", line)
+	}
+	telePeriod := r.TelePeriod
+	if telePeriod == 0 {
+		telePeriod = defaultTelePeriod
+	}
+	if r.For > 0 && r.For < telePeriod {
+		slog.Warn("Safety rule duration is shorter than telemetry period. Device is switched off after next telemetry, set TelePeriod on the device and teleperiod option.", "rule_line", line, "teleperiod", telePeriod)
+	}
+
+	lock.Lock()
+	safetyRules[device] = append(safetyRules[device], r)
+	lock.Unlock()
+}
+
+func parseSafetyRuleOption(r *SafetyRule, option string) error {
+	key, value, found := strings.Cut(option, "=")
+	if !found {
+		return fmt.Errorf("option %q is not in key=value format", option)
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "id":
+		r.ID = value
+	case "cooldown":
+		cooldown, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("can't parse cooldown %q: %w", value, err)
+		}
+		r.Cooldown = cooldown
+	case "retries":
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return fmt.Errorf("retries %q must be a number", value)
+		}
+		r.Retries = retries
+	case "teleperiod":
+		telePeriod, err := time.ParseDuration(value)
+		if err != nil || telePeriod <= 0 {
+			return fmt.Errorf("can't parse teleperiod %q", value)
+		}
+		r.TelePeriod = telePeriod
+	default:
+		return fmt.Errorf("unknown safety rule option %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package ruleengine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSafetyRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    SafetyRule
+		wantLog bool
+	}{
+		{
+			name: "without cooldown",
+			line: "__SAFETY__:::plug-heater:::ENERGY-->Power:::>150:::10m:::TELEGRAM_HOME:::id=charger-overload",
+			want: SafetyRule{ID: "charger-overload", JsonPath: "ENERGY-->Power", CompareValue: ">150", For: 10 * time.Minute, Recipients: "TELEGRAM_HOME", Retries: UnlimitedRetries},
+		},
+		{
+			name: "cooldown and retries",
+			line: "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::5m:::EMAIL_PARENTS:::cooldown=10m:::retries=3",
+			want: SafetyRule{ID: "safety:ENERGY-->Power>2000", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 5 * time.Minute, Recipients: "EMAIL_PARENTS", Cooldown: 10 * time.Minute, Retries: 3},
+		},
+		{
+			name: "cooldown without retries is unlimited",
+			line: "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::5m:::EMAIL_PARENTS:::id=heater:::cooldown=10m",
+			want: SafetyRule{ID: "heater", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 5 * time.Minute, Recipients: "EMAIL_PARENTS", Cooldown: 10 * time.Minute, Retries: UnlimitedRetries},
+		},
+		{
+			name: "zero retries locks out on first shutoff",
+			line: "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::5m:::EMAIL_PARENTS:::id=heater:::cooldown=10m:::retries=0",
+			want: SafetyRule{ID: "heater", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 5 * time.Minute, Recipients: "EMAIL_PARENTS", Cooldown: 10 * time.Minute, Retries: 0},
+		},
+		{
+			name:    "retries without cooldown",
+			line:    "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::5m:::EMAIL_PARENTS:::id=heater:::retries=3",
+			want:    SafetyRule{ID: "heater", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 5 * time.Minute, Recipients: "EMAIL_PARENTS", Retries: 3},
+			wantLog: true,
+		},
+		{
+			name:    "negative retries",
+			line:    "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::5m:::EMAIL_PARENTS:::id=heater:::cooldown=10m:::retries=-1",
+			want:    SafetyRule{ID: "heater", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 5 * time.Minute, Recipients: "EMAIL_PARENTS", Cooldown: 10 * time.Minute, Retries: UnlimitedRetries},
+			wantLog: true,
+		},
+		{
+			name: "duration with teleperiod",
+			line: "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::30s:::EMAIL_PARENTS:::id=heater:::teleperiod=10s",
+			want: SafetyRule{ID: "heater", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 30 * time.Second, Recipients: "EMAIL_PARENTS", Retries: UnlimitedRetries, TelePeriod: 10 * time.Second},
+		},
+		{
+			name:    "duration shorter than default teleperiod",
+			line:    "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::30s:::EMAIL_PARENTS:::id=heater",
+			want:    SafetyRule{ID: "heater", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 30 * time.Second, Recipients: "EMAIL_PARENTS", Retries: UnlimitedRetries},
+			wantLog: true,
+		},
+		{
+			name:    "duration shorter than teleperiod",
+			line:    "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::5m:::EMAIL_PARENTS:::id=heater:::teleperiod=10m",
+			want:    SafetyRule{ID: "heater", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 5 * time.Minute, Recipients: "EMAIL_PARENTS", Retries: UnlimitedRetries, TelePeriod: 10 * time.Minute},
+			wantLog: true,
+		},
+		{
+			name:    "zero teleperiod",
+			line:    "__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::5m:::EMAIL_PARENTS:::id=heater:::teleperiod=0s",
+			want:    SafetyRule{ID: "heater", JsonPath: "ENERGY-->Power", CompareValue: ">2000", For: 5 * time.Minute, Recipients: "EMAIL_PARENTS", Retries: UnlimitedRetries},
+			wantLog: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged := parseRuleLines(t, []string{tt.line})
+			if (len(logged) > 0) != tt.wantLog {
+				t.Errorf("logged %q, want log %v", logged, tt.wantLog)
+			}
+			rules := safetyRules["plug-heater"]
+			if len(rules) != 1 {
+				t.Fatalf("got %v safety rules, want 1", len(rules))
+			}
+			if rules[0] != tt.want {
+				t.Errorf("got %+v, want %+v", rules[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSafetyRuleErrors(t *testing.T) {
+	for _, line := range []string{
+		"__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::30s",
+		"__SAFETY__:::plug-heater:::ENERGY-->Power:::2000:::30s:::EMAIL_PARENTS",
+		"__SAFETY__:::plug-heater:::ENERGY-->Power:::>2000:::soon:::EMAIL_PARENTS",
+	} {
+		t.Run(line, func(t *testing.T) {
+			if logged := parseRuleLines(t, []string{line}); len(logged) == 0 {
+				t.Errorf("safety rule %q was parsed without error", line)
+			}
+			if rules := safetyRules["plug-heater"]; len(rules) > 0 {
+				t.Errorf("invalid safety rule was added: %+v", rules)
+			}
+		})
+	}
+}
@@ -18,11 +18,11 @@ func ReadFilesWithSuffix(fileFolder string, fileMask string) ([]string, error) {
 	err := filepath.Walk(fileFolder, func(path string, info os.FileInfo, err error) error {
         slog.Debug("Reading", "file", info.Name())
 		if err != nil {
-            slog.Debug("Error when reading file", err)
+            slog.Debug("Error when reading file", "error", err)
 			return err
 		}
 		if info.IsDir() {
-            slog.Debug("Ignoring folder", err)
+            slog.Debug("Ignoring folder", "error", err)
 			return nil
 		}
 
@@ -45,7 +45,7 @@ func ReadFilesWithSuffix(fileFolder string, fileMask string) ([]string, error) {
 			}
 			// Check for errors during scanning
 			if err := scanner.Err(); err != nil {
-                slog.Error("Error when scanning file", err)
+                slog.Error("Error when scanning file", "error", err)
 				return err
 			}
 		}